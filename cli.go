@@ -0,0 +1,114 @@
+package floki
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// ErrHandled is returned by Default when arguments given with WithArgs held a command
+// like "encrypt", and by Run and Listen when the application was started with -check-config
+// and config is valid. The work is done then, and the program should exit without serving:
+//     f, err := floki.Default(floki.WithArgs(os.Args[1:]))
+//     if err == floki.ErrHandled {
+//         return
+//     }
+var ErrHandled = errors.New("floki: handled without serving")
+
+// WithArgs makes the application handle command line arguments, usually os.Args[1:]:
+//     -config path     - config files pattern, see loadConfigFiles
+//     -check-config    - validate config of FLOKI_ENV environment, print problems and exit
+//     genkey, encrypt  - commands run by Default, see runCommand
+// Without this option the command line is left to the program.
+func WithArgs(args []string) Option {
+	return func(f *Floki) error {
+		flags := flag.NewFlagSet("floki", flag.ContinueOnError)
+		flags.SetOutput(ioutil.Discard)
+
+		configFile := flags.String("config", "", "Specify application config file to use")
+		checkConfig := flags.Bool("check-config", false, "Validate config of FLOKI_ENV environment, print problems and exit")
+
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+
+		if *configFile != "" && f.configFile == "" {
+			f.configFile = *configFile
+		}
+
+		f.checkConfigOnly = *checkConfig
+		f.command = flags.Args()
+		return nil
+	}
+}
+
+// runArgsCommand runs command given with WithArgs. It returns ErrHandled when the command succeeded.
+func (f *Floki) runArgsCommand() error {
+	handled, err := runCommand(f.command, os.Stdin, os.Stdout)
+	if !handled {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	return ErrHandled
+}
+
+// runCommand handles command given to the application instead of starting the server:
+//     genkey          - print new master key for FLOKI_MASTER_KEY
+//     encrypt [value] - print value encrypted with master key, reads stdin when value is omitted,
+//                       so it doesn't get into shell history
+// It reports whether args were a command.
+func runCommand(args []string, in io.Reader, out io.Writer) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	switch args[0] {
+	case "genkey":
+		key, err := GenerateMasterKey()
+		if err != nil {
+			return true, err
+		}
+
+		fmt.Fprintln(out, key)
+		return true, nil
+
+	case "encrypt":
+		key, err := masterKey()
+		if err != nil {
+			return true, err
+		}
+
+		var value string
+		if len(args) > 1 {
+			value = args[1]
+		} else {
+			value, err = bufio.NewReader(in).ReadString('\n')
+			if err != nil && err != io.EOF {
+				return true, err
+			}
+			value = strings.TrimRight(value, "\r\n")
+		}
+
+		if value == "" {
+			return true, errors.New("usage: encrypt [value], value is read from stdin when omitted")
+		}
+
+		encrypted, err := EncryptSecret(value, key)
+		if err != nil {
+			return true, err
+		}
+
+		fmt.Fprintln(out, encrypted)
+		return true, nil
+	}
+
+	return false, nil
+}
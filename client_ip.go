@@ -0,0 +1,185 @@
+package floki
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// proxyHeaders are headers which can carry client address set by a reverse proxy
+var proxyHeaders = map[string]bool{
+	"X-Forwarded-For": true,
+	"Forwarded":       true,
+	"X-Real-Ip":       true,
+}
+
+// SetTrustedProxies sets networks of reverse proxies whose forwarding header, see SetProxyHeader,
+// is trusted by Context.ClientIP. Both CIDRs ("10.0.0.0/8") and single addresses are accepted.
+// By default no proxy is trusted and the address of connected peer is used.
+func (f *Floki) SetTrustedProxies(proxies ...string) error {
+	nets, err := ParseNetworks(proxies)
+	if err != nil {
+		return err
+	}
+
+	f.trustedProxies = nets
+	return nil
+}
+
+// SetProxyHeader sets the header trusted proxies put client address to: X-Forwarded-For (default),
+// Forwarded or X-Real-IP. Only this header is read, because proxies pass other ones from
+// the client unchanged, e.g. nginx appends to X-Forwarded-For and keeps Forwarded as sent.
+func (f *Floki) SetProxyHeader(name string) error {
+	name = http.CanonicalHeaderKey(name)
+	if !proxyHeaders[name] {
+		return fmt.Errorf("unsupported proxy header %q, use X-Forwarded-For, Forwarded or X-Real-IP", name)
+	}
+
+	f.proxyHeader = name
+	return nil
+}
+
+// ClientIP returns IP address of the client. Forwarding headers are only followed
+// through proxies configured with SetTrustedProxies, so clients can't spoof their address.
+func (c *Context) ClientIP() string {
+	if c.clientIP == "" {
+		c.clientIP = resolveClientIP(c.Request, c.Floki.trustedProxies, c.Floki.proxyHeader)
+	}
+	return c.clientIP
+}
+
+func resolveClientIP(req *http.Request, trusted []*net.IPNet, proxyHeader string) string {
+	remote := stripPort(req.RemoteAddr)
+
+	ip := net.ParseIP(remote)
+	if ip == nil || !isTrusted(trusted, ip) {
+		return remote
+	}
+
+	chain := forwardedFor(req.Header, proxyHeader)
+	if len(chain) == 0 {
+		return remote
+	}
+
+	// walk from the closest hop to the client and stop at first untrusted address
+	client := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		hop := net.ParseIP(chain[i])
+		if hop == nil {
+			// obfuscated or garbage value, the last verified hop is the best we know
+			return client
+		}
+
+		client = hop.String()
+		if !isTrusted(trusted, hop) {
+			return client
+		}
+	}
+
+	return client
+}
+
+// forwardedFor returns addresses from proxyHeader, which is X-Forwarded-For when not set
+func forwardedFor(header http.Header, proxyHeader string) []string {
+	var chain []string
+
+	switch proxyHeader {
+	case "Forwarded":
+		// RFC 7239: for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"
+		for _, value := range header["Forwarded"] {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					pair = strings.TrimSpace(pair)
+					if len(pair) > 4 && strings.EqualFold(pair[:4], "for=") {
+						chain = append(chain, parseForwardedNode(pair[4:]))
+					}
+				}
+			}
+		}
+
+	case "X-Real-Ip":
+		if realIP := strings.TrimSpace(header.Get("X-Real-Ip")); realIP != "" {
+			chain = append(chain, realIP)
+		}
+
+	default:
+		for _, value := range header["X-Forwarded-For"] {
+			for _, addr := range strings.Split(value, ",") {
+				chain = append(chain, strings.TrimSpace(addr))
+			}
+		}
+	}
+
+	return chain
+}
+
+// parseForwardedNode extracts address from node value like `"[2001:db8::1]:4711"` or `192.0.2.60`
+func parseForwardedNode(node string) string {
+	node = strings.Trim(node, `"`)
+
+	if strings.HasPrefix(node, "[") {
+		if end := strings.Index(node, "]"); end > 0 {
+			return node[1:end]
+		}
+		return node
+	}
+
+	return stripPort(node)
+}
+
+func stripPort(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+func isTrusted(trusted []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseNetworks parses list of CIDRs or single IP addresses, see ParseNetwork.
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		ipNet, err := ParseNetwork(value)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ParseNetwork parses CIDR like "10.0.0.0/8" or single IP address, which becomes
+// a network of one address.
+func ParseNetwork(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address: %q", value)
+		}
+
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, ipNet, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network: %q", value)
+	}
+	return ipNet, nil
+}
@@ -0,0 +1,79 @@
+package floki
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	f := Must(New())
+	if err := f.SetTrustedProxies("10.0.0.0/8", "192.168.1.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		proxyHeader string
+		remote      string
+		headers     map[string]string
+		ip          string
+	}{
+		// untrusted peer can't spoof the address
+		{"", "203.0.113.5:1000", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.5"},
+		{"", "10.0.0.1:1000", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "1.2.3.4"},
+		// the client prepended a fake address, only the one added by our proxy counts
+		{"", "10.0.0.1:1000", map[string]string{"X-Forwarded-For": "6.6.6.6, 1.2.3.4, 10.1.1.1"}, "1.2.3.4"},
+		// headers other than the configured one are passed from the client by the proxy
+		{"", "10.0.0.1:1000", map[string]string{"X-Forwarded-For": "203.0.113.9", "Forwarded": "for=10.9.9.9"}, "203.0.113.9"},
+		{"", "10.0.0.1:1000", map[string]string{"Forwarded": "for=10.9.9.9", "X-Real-IP": "10.9.9.9"}, "10.0.0.1"},
+		{"X-Real-IP", "192.168.1.1:1000", map[string]string{"X-Real-IP": "1.2.3.4"}, "1.2.3.4"},
+		{"X-Real-IP", "192.168.1.2:1000", map[string]string{"X-Real-IP": "1.2.3.4"}, "192.168.1.2"},
+		{"X-Real-IP", "192.168.1.1:1000", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "192.168.1.1"},
+		{"Forwarded", "10.0.0.1:1000", map[string]string{"Forwarded": `for=6.6.6.6, for="[2001:db8::1]:4711";proto=https`}, "2001:db8::1"},
+		{"Forwarded", "10.0.0.1:1000", map[string]string{"Forwarded": "for=_hidden, for=10.2.2.2"}, "10.2.2.2"},
+		{"forwarded", "10.0.0.1:1000", map[string]string{"X-Forwarded-For": "1.2.3.4", "Forwarded": "for=5.6.7.8"}, "5.6.7.8"},
+	}
+
+	for _, test := range tests {
+		f.proxyHeader = ""
+		if test.proxyHeader != "" {
+			if err := f.SetProxyHeader(test.proxyHeader); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remote
+		for k, v := range test.headers {
+			req.Header.Set(k, v)
+		}
+
+		c := &Context{Request: req, Floki: f}
+		if ip := c.ClientIP(); ip != test.ip {
+			t.Errorf("%s %s %v: expected %s, got %s", test.proxyHeader, test.remote, test.headers, test.ip, ip)
+		}
+	}
+
+	if err := f.SetProxyHeader("X-Client-IP"); err == nil {
+		t.Error("unsupported proxy header should be rejected")
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	nets, err := ParseNetworks([]string{"10.0.0.0/8", " 192.168.1.1 ", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"10.0.0.0/8", "192.168.1.1/32", "::1/128"}
+	for i, ipNet := range nets {
+		if ipNet.String() != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], ipNet)
+		}
+	}
+
+	for _, value := range []string{"not a network", "10.0.0.0/33", ""} {
+		if _, err = ParseNetworks([]string{value}); err == nil {
+			t.Errorf("%q should be rejected", value)
+		}
+	}
+}
@@ -2,53 +2,89 @@ package floki
 
 import (
 	"encoding/json"
-	"flag"
 	"fmt"
 	"io/ioutil"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var (
-	ConfigFile = flag.String("config", "./app/config/%s.json", "Specify application config file to use")
-)
+// defaultConfigFile is used when config path is not given by WithConfigFile or -config argument
+const defaultConfigFile = "./app/config/%s.json"
 
+// ConfigMap holds application config merged from config files, see loadConfigFiles.
+// Values can be overridden by environment variables, see SetEnv.
+//
+// Getters like Int or Duration return the default value when the key is missing and
+// when its value can't be converted to the requested type, e.g. Int of "port": "3000".
+// Use Decode to get errors of such values reported.
 type ConfigMap struct {
 	data map[string]*json.RawMessage
-}
 
-func (f *Floki) loadConfig() {
-	logger := f.logger
+	// files merged into the config, in order
+	files []string
 
-	f.triggerAppEvent("ConfigureAppStart")
+	// path of keys from the root config to this section
+	path []string
+	env  *configEnv
+
+	// root config which can be reloaded keeps its data here
+	store *configStore
+}
 
-	flag.Parse()
+// loadConfig loads config files given by WithConfigFile option or -config argument, unless
+// config was given with WithConfig, and applies framework settings from it.
+func (f *Floki) loadConfig() error {
+	if !f.configGiven {
+		if f.configFile == "" {
+			f.configFile = defaultConfigFile
+		}
+		f.configFile = f.path(f.configFile)
+
+		config, err := loadConfigFiles(f.configFile, f.Env)
+		if err != nil {
+			return fmt.Errorf("error loading config: %v", err)
+		}
 
-	configFileName := fmt.Sprintf(*ConfigFile, Env)
-	if Env == Dev {
-		logger.Println("Using config file:", configFileName)
+		f.Config = config
 	}
 
-	err := loadConfig(configFileName, &f.Config)
-	if err != nil {
-		logger.Fatalln("Error loading config file:", configFileName, ":", err)
+	f.Config.reloadable()
+
+	f.Config.SetEnv(f.Config.Str("envPrefix", "FLOKI_"), envNamings[f.Config.Str("envNaming", "snake")])
+
+	// config may change log level and format
+	f.configureLog(f.logOutput)
+
+	// init time zone unless it was given as option
+	if f.TimeZone == nil {
+		timeZoneStr := f.Config.Str("timeZone", "")
+
+		loc, err := time.LoadLocation(timeZoneStr)
+		if err != nil {
+			f.log.Warn("invalid timezone in configuration file, falling back to UTC", "timeZone", timeZoneStr)
+			loc = time.UTC
+		}
+		f.TimeZone = loc
 	}
 
-	// init time zone
-	timeZoneStr := f.Config.Str("timeZone", "")
+	if err := f.SetTrustedProxies(f.Config.Strings("trustedProxies", nil)...); err != nil {
+		f.log.Error("invalid trustedProxies in config", "error", err)
+	}
 
-	TimeZone, err = time.LoadLocation(timeZoneStr)
-	if err != nil {
-		logger.Println("Invalid timezone in configuration file specified:", timeZoneStr, ". Falling back to UTC")
-		TimeZone, err = time.LoadLocation("")
+	if err := f.SetProxyHeader(f.Config.Str("proxyHeader", "X-Forwarded-For")); err != nil {
+		f.log.Error("invalid proxyHeader in config", "error", err)
 	}
 
-	f.triggerAppEvent("ConfigureAppEnd")
+	f.log.Info("loaded config", "files", strings.Join(f.Config.Files(), ", "))
 
-	logger.Println("loaded config:", configFileName)
+	if f.Config.Bool("configWatch", false) {
+		f.watchConfigFiles()
+	}
 
 	// load build number
-	if Env == Prod {
-		bnum, err := ioutil.ReadFile("build.num")
+	if f.Env == Prod {
+		bnum, err := ioutil.ReadFile(f.path("build.num"))
 		if err == nil {
 			f.BuildNumber = string(bnum)
 		}
@@ -56,57 +92,216 @@ func (f *Floki) loadConfig() {
 	} else {
 		f.BuildNumber = ""
 	}
+
+	return nil
 }
 
-func (c ConfigMap) Bool(key string, defaultValue bool) bool {
-	v := c.data[key]
+// value unmarshals value of the key into v and reports whether it's set and can be
+// converted to v. Getters return their default value otherwise.
+func (c ConfigMap) value(key string, v interface{}) bool {
+	found, err := c.lookup(key, v)
+	return found && err == nil
+}
 
-	if v == nil {
-		return defaultValue
+// lookup unmarshals value of the key into v. Environment variable overrides value from file.
+// Dotted key like "db.primary.host" is looked up in nested sections unless the section
+// has a key with dots itself.
+func (c ConfigMap) lookup(key string, v interface{}) (found bool, err error) {
+	c, key = c.current().section(key)
+
+	if value, ok := c.lookupEnv(key); ok {
+		return true, setEnvValue(value, v)
 	}
 
-	var b bool
-	json.Unmarshal(*v, &b)
-	return b
+	raw := c.data[key]
+	if raw == nil {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(interpolateEnv(*raw), v)
 }
 
-func (c ConfigMap) Int(key string, defaultValue int) int {
-	v := c.data[key]
+// section resolves dotted key to nested section and the last key in it
+func (c ConfigMap) section(key string) (ConfigMap, string) {
+	dot := strings.IndexByte(key, '.')
+	if dot < 0 || c.data[key] != nil {
+		return c, key
+	}
 
-	if v == nil {
+	return c.Map(key[:dot]).section(key[dot+1:])
+}
+
+func (c ConfigMap) Bool(key string, defaultValue bool) bool {
+	var b bool
+	if !c.value(key, &b) {
 		return defaultValue
 	}
+	return b
+}
 
+func (c ConfigMap) Int(key string, defaultValue int) int {
 	var i int
-	json.Unmarshal(*v, &i)
+	if !c.value(key, &i) {
+		return defaultValue
+	}
 	return i
 }
 
 func (c ConfigMap) Str(key string, defaultValue string) string {
-	v := c.data[key]
+	var s string
+	if !c.value(key, &s) {
+		return defaultValue
+	}
+	return s
+}
 
-	if v == nil {
+// Strings returns value of the key as a list of strings. Environment variable
+// overriding it holds comma separated list.
+func (c ConfigMap) Strings(key string, defaultValue []string) []string {
+	var s []string
+	if !c.value(key, &s) {
 		return defaultValue
 	}
+	return s
+}
 
+// Duration returns value of the key parsed by time.ParseDuration, e.g. "30s" or "1h30m"
+func (c ConfigMap) Duration(key string, defaultValue time.Duration) time.Duration {
 	var s string
-	json.Unmarshal(*v, &s)
-	return s
+	if !c.value(key, &s) {
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return defaultValue
+	}
+	return d
+}
+
+// Float64 returns value of the key as a floating point number
+func (c ConfigMap) Float64(key string, defaultValue float64) float64 {
+	var f float64
+	if !c.value(key, &f) {
+		return defaultValue
+	}
+	return f
+}
+
+// Ints returns value of the key as a list of integers. Environment variable
+// overriding it holds comma separated list.
+func (c ConfigMap) Ints(key string, defaultValue []int) []int {
+	var i []int
+	if !c.value(key, &i) {
+		return defaultValue
+	}
+	return i
+}
+
+// Bytes returns size in bytes given as a number or a string with unit, e.g. "512KB" or "10MB".
+// Units are binary, so "1KB" and "1KiB" are both 1024 bytes.
+func (c ConfigMap) Bytes(key string, defaultValue int64) int64 {
+	var raw interface{}
+	if !c.value(key, &raw) {
+		return defaultValue
+	}
+
+	switch v := raw.(type) {
+	case float64:
+		return int64(v)
+	case string:
+		size, err := ParseBytes(v)
+		if err != nil {
+			return defaultValue
+		}
+		return size
+	}
+
+	return defaultValue
+}
+
+// Time returns value of the key given in RFC 3339 format, e.g. "2024-03-01T10:00:00Z",
+// or as a date "2024-03-01". Times without zone are in UTC.
+func (c ConfigMap) Time(key string, defaultValue time.Time) time.Time {
+	var s string
+	if !c.value(key, &s) {
+		return defaultValue
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+
+	return defaultValue
+}
+
+// ByteSize is a size in bytes decoded from a number or a string with unit like "10MB", see ParseBytes.
+type ByteSize int64
+
+// ParseBytes parses size with optional unit B, KB, MB, GB or TB, case insensitive.
+// Units are binary, "KiB" style is accepted as well.
+func ParseBytes(s string) (int64, error) {
+	value := strings.TrimSpace(s)
+
+	i := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+
+	number, unit := value, ""
+	if i >= 0 {
+		number, unit = value[:i], strings.TrimSpace(value[i:])
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	unit = strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(unit), "B"), "I")
+
+	var shift uint
+	switch unit {
+	case "":
+	case "K":
+		shift = 10
+	case "M":
+		shift = 20
+	case "G":
+		shift = 30
+	case "T":
+		shift = 40
+	default:
+		return 0, fmt.Errorf("invalid size unit in %q", s)
+	}
+
+	return int64(n * float64(int64(1)<<shift)), nil
 }
 
+// Map returns nested section of the key, dotted keys are supported as well.
+// Sections of the application config read through it, so they see reloaded values.
 func (c ConfigMap) Map(key string) ConfigMap {
-	v := c.data[key]
+	c, key = c.current().section(key)
+	child := ConfigMap{path: c.childPath(key), env: c.env, store: c.store}
+
+	if child.store != nil {
+		return child.current()
+	}
 
+	v := c.data[key]
 	if v == nil {
-		return ConfigMap{make(map[string]*json.RawMessage)}
+		child.data = make(map[string]*json.RawMessage)
+		return child
 	}
 
-	var m map[string]*json.RawMessage
-	json.Unmarshal(*v, &m)
-	return ConfigMap{m}
+	json.Unmarshal(*v, &child.data)
+	return child
 }
 
 func (c ConfigMap) Keys() []string {
+	c = c.current()
+
 	var keys []string
 	for k := range c.data {
 		keys = append(keys, k)
@@ -115,21 +310,8 @@ func (c ConfigMap) Keys() []string {
 }
 
 func (c ConfigMap) EachMap(iterator func(key string, value ConfigMap)) {
+	c = c.current()
 	for k := range c.data {
-		var m map[string]*json.RawMessage
-		json.Unmarshal(*c.data[k], &m)
-		iterator(k, ConfigMap{m})
-	}
-}
-
-// loadConfig gets your config from the json file,
-// and returns resulting ConfigMap
-func loadConfig(filename string, o *ConfigMap) error {
-	b, err := ioutil.ReadFile(filename)
-	if err == nil {
-		err = json.Unmarshal(b, &o.data)
-		return err
+		iterator(k, c.Map(k))
 	}
-
-	return err
 }
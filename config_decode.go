@@ -0,0 +1,264 @@
+package floki
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type (
+	// ConfigError describes invalid or missing config key.
+	ConfigError struct {
+		Key string
+		Err error
+	}
+
+	// ConfigErrors lists every problem found by Decode and DecodeAll.
+	ConfigErrors []*ConfigError
+)
+
+var (
+	errMissingKey = errors.New("required key is missing")
+	durationType  = reflect.TypeOf(time.Duration(0))
+	timeType      = reflect.TypeOf(time.Time{})
+	secretType    = reflect.TypeOf(Secret(""))
+	byteSizeType  = reflect.TypeOf(ByteSize(0))
+)
+
+func (e *ConfigError) Error() string {
+	return e.Key + ": " + e.Err.Error()
+}
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// Decode fills struct pointed by v from config section key. See DecodeAll for supported tags.
+func (c ConfigMap) Decode(key string, v interface{}) error {
+	return c.Map(key).DecodeAll(v)
+}
+
+// DecodeAll fills struct pointed by v from the whole config map. Fields are matched by
+// "config" tag, then by "json" tag, then by field name starting with lower case letter.
+// Nested structs are decoded from nested sections. Values are overridden by environment
+// variables just like with other getters.
+//     type AppConfig struct {
+//         Port    int           `config:"port" default:"3000"`
+//         Timeout time.Duration `default:"30s"`
+//         MaxBody ByteSize      `default:"10MB"`
+//         DB      struct {
+//             DSN      string `config:"dsn" required:"true"`
+//             Password Secret `config:"password"`
+//             MaxConns int    `config:"maxConns" default:"10"`
+//         } `config:"db"`
+//     }
+// Fields of Secret type and string fields tagged with secret:"true" resolve secret references,
+// see ConfigMap.Secret.
+// Instead of stopping at the first problem, it returns ConfigErrors listing every
+// missing required key and every value which can't be converted to field type.
+// Return it from OnConfigure hook to abort startup on invalid config.
+func (c ConfigMap) DecodeAll(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config can only be decoded into pointer to struct, got %T", v)
+	}
+
+	var errs ConfigErrors
+	c.current().decodeStruct(rv.Elem(), &errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (c ConfigMap) decodeStruct(rv reflect.Value, errs *ConfigErrors) {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		fv := rv.Field(i)
+
+		// embedded struct shares the section with its parent
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("config") == "" {
+			c.decodeStruct(fv, errs)
+			continue
+		}
+
+		key := configKey(field)
+		if key == "" {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct && field.Type != timeType {
+			c.Map(key).decodeStruct(fv, errs)
+			continue
+		}
+
+		if err := c.decodeField(key, field, fv); err != nil {
+			*errs = append(*errs, &ConfigError{strings.Join(c.childPath(key), "."), err})
+		}
+	}
+}
+
+// decodeField sets field from environment variable, config file or default tag, in this order.
+// Secret references are resolved in fields of Secret type or tagged with secret:"true".
+func (c ConfigMap) decodeField(key string, field reflect.StructField, fv reflect.Value) error {
+	if err := c.setField(key, field, fv); err != nil || !isSecretField(field) {
+		return err
+	}
+
+	value, err := resolveSecret(fv.String())
+	if err != nil {
+		return err
+	}
+
+	fv.SetString(value)
+	return nil
+}
+
+func isSecretField(field reflect.StructField) bool {
+	return field.Type == secretType || (field.Type.Kind() == reflect.String && field.Tag.Get("secret") == "true")
+}
+
+func (c ConfigMap) setField(key string, field reflect.StructField, fv reflect.Value) error {
+	if value, ok := c.lookupEnv(key); ok {
+		return setFieldFromString(fv, value)
+	}
+
+	if raw := c.data[key]; raw != nil {
+		return setFieldFromJSON(fv, interpolateEnv(*raw))
+	}
+
+	if value, ok := field.Tag.Lookup("default"); ok {
+		if err := setFieldFromString(fv, value); err != nil {
+			return fmt.Errorf("invalid default: %v", err)
+		}
+		return nil
+	}
+
+	if required, _ := strconv.ParseBool(field.Tag.Get("required")); required {
+		return errMissingKey
+	}
+
+	return nil
+}
+
+// configKey returns config key of the struct field, or empty string if the field is skipped
+func configKey(field reflect.StructField) string {
+	for _, tag := range []string{"config", "json"} {
+		if name := strings.Split(field.Tag.Get(tag), ",")[0]; name == "-" {
+			return ""
+		} else if name != "" {
+			return name
+		}
+	}
+
+	r, size := utf8.DecodeRuneInString(field.Name)
+	return string(unicode.ToLower(r)) + field.Name[size:]
+}
+
+func setFieldFromJSON(fv reflect.Value, raw []byte) error {
+	// durations and sizes are written as strings like "30s" or "1MB"
+	if fv.Type() == durationType || fv.Type() == byteSizeType {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			return setFieldFromString(fv, s)
+		}
+	}
+
+	ptr := reflect.New(fv.Type())
+	if err := json.Unmarshal(raw, ptr.Interface()); err != nil {
+		return fmt.Errorf("can't use %s as %s", raw, fv.Type())
+	}
+
+	fv.Set(ptr.Elem())
+	return nil
+}
+
+// setFieldFromString converts value of environment variable or default tag to field type
+func setFieldFromString(fv reflect.Value, value string) error {
+	invalid := fmt.Errorf("can't use %q as %s", value, fv.Type())
+
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return invalid
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	if fv.Type() == byteSizeType {
+		size, err := ParseBytes(value)
+		if err != nil {
+			return invalid
+		}
+		fv.SetInt(size)
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalid
+		}
+		fv.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 0, fv.Type().Bits())
+		if err != nil {
+			return invalid
+		}
+		fv.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 0, fv.Type().Bits())
+		if err != nil {
+			return invalid
+		}
+		fv.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return invalid
+		}
+		fv.SetFloat(f)
+
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(value, "[") {
+			items := splitList(value)
+			fv.Set(reflect.ValueOf(items).Convert(fv.Type()))
+			return nil
+		}
+		return setFieldFromJSON(fv, []byte(value))
+
+	default:
+		// maps, time.Time and other types are written as json
+		if fv.Type() == timeType {
+			return setFieldFromJSON(fv, []byte(strconv.Quote(value)))
+		}
+		return setFieldFromJSON(fv, []byte(value))
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package floki
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testDBConfig struct {
+	DSN      string `config:"dsn" required:"true"`
+	MaxConns int    `config:"maxConns" default:"10"`
+}
+
+type testAppConfig struct {
+	Port     int           `default:"3000"`
+	Timeout  time.Duration `default:"30s"`
+	MaxBody  ByteSize      `default:"1MB"`
+	Hosts    []string
+	Debug    bool         `json:"debugMode"`
+	Ignored  string       `config:"-"`
+	DB       testDBConfig `config:"db"`
+	internal string
+}
+
+func TestConfigDecodeAll(t *testing.T) {
+	var c ConfigMap
+	json.Unmarshal([]byte(`{
+		"timeout": "5s",
+		"hosts": ["a", "b"],
+		"debugMode": true,
+		"ignored": "value",
+		"db": {"dsn": "postgres://localhost"}
+	}`), &c.data)
+	c.SetEnv("APP_", nil)
+	t.Setenv("APP_DB_MAX_CONNS", "20")
+	t.Setenv("APP_MAX_BODY", "2KB")
+
+	var conf testAppConfig
+	if err := c.DecodeAll(&conf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := testAppConfig{
+		Port:    3000,
+		Timeout: 5 * time.Second,
+		MaxBody: 2048,
+		Hosts:   []string{"a", "b"},
+		Debug:   true,
+		DB:      testDBConfig{"postgres://localhost", 20},
+	}
+
+	if !reflect.DeepEqual(conf, expected) {
+		t.Errorf("expected %+v, got %+v", expected, conf)
+	}
+}
+
+func TestConfigDecodeErrors(t *testing.T) {
+	var c ConfigMap
+	json.Unmarshal([]byte(`{
+		"port": "http",
+		"timeout": "soon",
+		"maxBody": "lots",
+		"db": {"maxConns": 10}
+	}`), &c.data)
+
+	var conf testAppConfig
+	err := c.DecodeAll(&conf)
+
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+
+	if len(errs) != 4 {
+		t.Errorf("expected 4 errors, got %v", err)
+	}
+
+	for _, key := range []string{"port", "timeout", "maxBody", "db.dsn: required key is missing"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error should mention %q: %v", key, err)
+		}
+	}
+}
+
+func TestConfigDecodeSection(t *testing.T) {
+	var c ConfigMap
+	json.Unmarshal([]byte(`{"db": {"dsn": "postgres://localhost"}}`), &c.data)
+
+	var db testDBConfig
+	if err := c.Decode("db", &db); err != nil {
+		t.Fatal(err)
+	}
+
+	if db.DSN != "postgres://localhost" || db.MaxConns != 10 {
+		t.Errorf("unexpected config %+v", db)
+	}
+}
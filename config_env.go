@@ -0,0 +1,190 @@
+package floki
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type (
+	// EnvNaming builds environment variable name, without prefix, from path of config keys,
+	// e.g. ["db", "maxConns"].
+	EnvNaming func(path []string) string
+
+	configEnv struct {
+		prefix string
+		naming EnvNaming
+	}
+)
+
+// envNamings are naming conventions selected by "envNaming" config key
+var envNamings = map[string]EnvNaming{
+	"snake": EnvSnakeCase,
+	"exact": EnvExact,
+}
+
+// ${VAR} or ${VAR:-default} inside json strings
+var envRefRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// SetEnv enables overriding config values by environment variables. Name of the variable
+// is prefix followed by key path converted with naming, EnvSnakeCase if nil:
+//     "logLevel"                      -> FLOKI_LOG_LEVEL
+//     "db": {"maxConns": 10}          -> FLOKI_DB_MAX_CONNS
+// Sections returned by Map and EachMap are overridden the same way. Lists are
+// given as comma separated values or json arrays.
+//
+// Default() enables overrides with prefix from "envPrefix" config key, "FLOKI_" by default,
+// and naming from "envNaming": "snake" or "exact".
+func (c *ConfigMap) SetEnv(prefix string, naming EnvNaming) {
+	if naming == nil {
+		naming = EnvSnakeCase
+	}
+	c.env = &configEnv{prefix, naming}
+}
+
+// EnvSnakeCase converts key path to upper snake case: ["db", "maxConns"] -> DB_MAX_CONNS
+func EnvSnakeCase(path []string) string {
+	var b strings.Builder
+
+	for i, key := range path {
+		if i > 0 {
+			b.WriteByte('_')
+		}
+
+		runes := []rune(key)
+		for j, r := range runes {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				b.WriteByte('_')
+				continue
+			}
+
+			// word boundary: "maxConns" or the last capital of acronym in "HTTPServer"
+			if j > 0 && unicode.IsUpper(r) {
+				prev := runes[j-1]
+				if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+					unicode.IsUpper(prev) && j+1 < len(runes) && unicode.IsLower(runes[j+1]) {
+					b.WriteByte('_')
+				}
+			}
+
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
+
+	return b.String()
+}
+
+// EnvExact joins keys as they are: ["db", "maxConns"] -> db_maxConns
+func EnvExact(path []string) string {
+	return strings.Join(path, "_")
+}
+
+// childPath returns path of the key in this section
+func (c ConfigMap) childPath(key string) []string {
+	path := make([]string, len(c.path), len(c.path)+1)
+	copy(path, c.path)
+	return append(path, key)
+}
+
+// lookupEnv returns environment variable overriding the key
+func (c ConfigMap) lookupEnv(key string) (string, bool) {
+	if c.env == nil {
+		return "", false
+	}
+
+	return os.LookupEnv(c.env.prefix + c.env.naming(c.childPath(key)))
+}
+
+// setEnvValue converts environment variable value into v. Booleans and numbers are parsed
+// like command line flags, so FLOKI_ACCESS_LOG=1 or TRUE enables the option.
+func setEnvValue(value string, v interface{}) error {
+	var err error
+
+	switch v := v.(type) {
+	case *string:
+		*v = value
+
+	case *bool:
+		*v, err = strconv.ParseBool(strings.TrimSpace(value))
+
+	case *int:
+		*v, err = strconv.Atoi(strings.TrimSpace(value))
+
+	case *int64:
+		*v, err = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+
+	case *float64:
+		*v, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+
+	case *interface{}:
+		// values like "10MB" are not valid json, they are kept as strings
+		if json.Unmarshal([]byte(value), v) != nil {
+			*v = value
+		}
+
+	case *[]string:
+		if strings.HasPrefix(value, "[") && json.Unmarshal([]byte(value), v) == nil {
+			return nil
+		}
+
+		*v = nil
+		for _, item := range splitList(value) {
+			*v = append(*v, item)
+		}
+
+	case *[]int:
+		if strings.HasPrefix(value, "[") {
+			return json.Unmarshal([]byte(value), v)
+		}
+
+		*v = nil
+		for _, item := range splitList(value) {
+			i, err := strconv.Atoi(item)
+			if err != nil {
+				return err
+			}
+			*v = append(*v, i)
+		}
+
+	default:
+		return json.Unmarshal([]byte(value), v)
+	}
+
+	return err
+}
+
+// splitList splits comma separated list skipping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// interpolateEnv replaces ${VAR} and ${VAR:-default} references inside json strings
+// with values of environment variables
+func interpolateEnv(raw []byte) []byte {
+	if !envRefRe.Match(raw) {
+		return raw
+	}
+
+	return envRefRe.ReplaceAllFunc(raw, func(ref []byte) []byte {
+		match := envRefRe.FindSubmatch(ref)
+
+		value, ok := os.LookupEnv(string(match[1]))
+		if !ok {
+			// default is part of json string already
+			return match[2]
+		}
+
+		// the value is placed inside json string, so it has to be escaped
+		quoted, _ := json.Marshal(value)
+		return quoted[1 : len(quoted)-1]
+	})
+}
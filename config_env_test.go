@@ -0,0 +1,125 @@
+package floki
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEnvSnakeCase(t *testing.T) {
+	tests := map[string][]string{
+		"LOG_LEVEL":        {"logLevel"},
+		"LOG_MAX_SIZE_MB":  {"logMaxSizeMB"},
+		"H2C":              {"h2c"},
+		"DB_MAX_CONNS":     {"db", "maxConns"},
+		"HTTP_SERVER_PORT": {"HTTPServer", "port"},
+		"TLS_EXAMPLE_ORG":  {"tls", "example.org"},
+	}
+
+	for expected, path := range tests {
+		if name := EnvSnakeCase(path); name != expected {
+			t.Errorf("%v: expected %s, got %s", path, expected, name)
+		}
+	}
+}
+
+func TestConfigEnvOverrides(t *testing.T) {
+	var c ConfigMap
+	json.Unmarshal([]byte(`{
+		"port": 3000,
+		"debug": false,
+		"name": "app",
+		"db": {"dsn": "postgres://localhost", "maxConns": 10},
+		"hosts": ["a"]
+	}`), &c.data)
+	c.SetEnv("APP_", nil)
+
+	t.Setenv("APP_PORT", "8080")
+	t.Setenv("APP_DEBUG", "true")
+	t.Setenv("APP_DB_MAX_CONNS", "20")
+	t.Setenv("APP_HOSTS", "b, c")
+	t.Setenv("APP_TIMEOUT", "5s")
+
+	if port := c.Int("port", 0); port != 8080 {
+		t.Errorf("port should be overridden, got %d", port)
+	}
+	if !c.Bool("debug", false) {
+		t.Error("debug should be overridden")
+	}
+	if name := c.Str("name", ""); name != "app" {
+		t.Errorf("name should be taken from file, got %s", name)
+	}
+	if conns := c.Map("db").Int("maxConns", 0); conns != 20 {
+		t.Errorf("nested value should be overridden, got %d", conns)
+	}
+	if hosts := c.Strings("hosts", nil); !reflect.DeepEqual(hosts, []string{"b", "c"}) {
+		t.Errorf("list should be overridden, got %v", hosts)
+	}
+	if timeout := c.Duration("timeout", 0); timeout != 5*time.Second {
+		t.Errorf("missing key should be set from env, got %s", timeout)
+	}
+
+	c.EachMap(func(key string, section ConfigMap) {
+		if key == "db" && section.Int("maxConns", 0) != 20 {
+			t.Error("sections iterated by EachMap should be overridden")
+		}
+	})
+}
+
+func TestConfigEnvScalars(t *testing.T) {
+	var c ConfigMap
+	c.SetEnv("APP_", nil)
+
+	tests := []struct {
+		value    string
+		boolean  bool
+		number   int
+		fraction float64
+	}{
+		{"1", true, 1, 1},
+		{"TRUE", true, 7, 7},
+		{"false", false, 7, 7},
+		{" 42 ", true, 42, 42},
+		{"0.5", true, 7, 0.5},
+		{"yes", true, 7, 7},
+	}
+
+	// invalid values fall back to defaults instead of zero values
+	for _, test := range tests {
+		t.Setenv("APP_VALUE", test.value)
+
+		if b := c.Bool("value", true); b != test.boolean {
+			t.Errorf("%q: expected bool %v, got %v", test.value, test.boolean, b)
+		}
+		if i := c.Int("value", 7); i != test.number {
+			t.Errorf("%q: expected int %d, got %d", test.value, test.number, i)
+		}
+		if f := c.Float64("value", 7); f != test.fraction {
+			t.Errorf("%q: expected float %v, got %v", test.value, test.fraction, f)
+		}
+	}
+
+	t.Setenv("APP_MAX_BODY", "10MB")
+	if size := c.Bytes("maxBody", 0); size != 10<<20 {
+		t.Errorf("size with unit should be parsed, got %d", size)
+	}
+}
+
+func TestConfigInterpolation(t *testing.T) {
+	var c ConfigMap
+	json.Unmarshal([]byte(`{
+		"dsn": "postgres://${DB_USER}@${DB_HOST:-localhost}/app",
+		"db": {"password": "${DB_PASSWORD}"}
+	}`), &c.data)
+
+	t.Setenv("DB_USER", "floki")
+	t.Setenv("DB_PASSWORD", `p"a\ss`)
+
+	if dsn := c.Str("dsn", ""); dsn != "postgres://floki@localhost/app" {
+		t.Errorf("unexpected dsn %s", dsn)
+	}
+	if password := c.Map("db").Str("password", ""); password != `p"a\ss` {
+		t.Errorf("unexpected password %s", password)
+	}
+}
@@ -0,0 +1,298 @@
+package floki
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// includeKey lists files merged under the content of the file which includes them
+const includeKey = "include"
+
+// loadConfigFiles merges config files found by pattern, which is the -config flag.
+// Format is detected by file extension: .json, .yaml, .yml or .toml. When the file
+// doesn't exist, the same name with other extensions is tried, so production.yaml is
+// found by the default pattern as well. When pattern has %s placeholder, these files
+// are merged in order:
+//     base.json          - optional, settings shared by all environments
+//     <env>.json         - settings of the environment, e.g. production.json
+//     local.json         - optional, settings of the machine, not meant to be committed
+// Sections are merged deeply, other values including arrays are replaced, and null
+// removes the key. Any file can include other files, paths are relative to it:
+//     "include": ["db.json", "mail.json"]
+// Included files are merged in order, then the file itself is merged over them.
+func loadConfigFiles(pattern, env string) (ConfigMap, error) {
+	loader := &configLoader{tree: make(map[string]interface{})}
+
+	if !strings.Contains(pattern, "%s") {
+		filename, _ := findConfigFile(pattern)
+		if err := loader.merge(filename); err != nil {
+			return ConfigMap{}, err
+		}
+		return loader.configMap()
+	}
+
+	layers := []struct {
+		name     string
+		optional bool
+	}{
+		{"base", true},
+		{env, false},
+		{"local", true},
+	}
+
+	for _, layer := range layers {
+		filename, found := findConfigFile(fmt.Sprintf(pattern, layer.name))
+		if !found && layer.optional {
+			continue
+		}
+
+		if err := loader.merge(filename); err != nil {
+			return ConfigMap{}, err
+		}
+	}
+
+	return loader.configMap()
+}
+
+type configLoader struct {
+	tree  map[string]interface{}
+	files []string
+
+	// files being merged, to detect include cycles
+	including []string
+}
+
+// merge reads filename and merges it with its includes into the tree
+func (l *configLoader) merge(filename string) error {
+	filename = filepath.Clean(filename)
+
+	for _, parent := range l.including {
+		if parent == filename {
+			return fmt.Errorf("%s: include cycle: %s", filename, strings.Join(append(l.including, filename), " -> "))
+		}
+	}
+
+	l.including = append(l.including, filename)
+	defer func() {
+		l.including = l.including[:len(l.including)-1]
+	}()
+
+	tree, err := readConfigFile(filename)
+	if err != nil {
+		return err
+	}
+
+	includes, err := configIncludes(tree[includeKey])
+	if err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	delete(tree, includeKey)
+
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(filename), include)
+		}
+
+		if err := l.merge(include); err != nil {
+			return err
+		}
+	}
+
+	mergeConfigTree(l.tree, tree)
+	l.files = append(l.files, filename)
+
+	return nil
+}
+
+func (l *configLoader) configMap() (ConfigMap, error) {
+	data, err := json.Marshal(l.tree)
+	if err != nil {
+		return ConfigMap{}, err
+	}
+
+	c := ConfigMap{files: l.files}
+	return c, json.Unmarshal(data, &c.data)
+}
+
+// configFormats parse config files by extension into generic tree
+var configFormats = map[string]func(data []byte) (map[string]interface{}, error){
+	".json": parseJSONConfig,
+	".yaml": parseYAMLConfig,
+	".yml":  parseYAMLConfig,
+	".toml": parseTOMLConfig,
+}
+
+// ParseConfig parses config data in format "json", "yaml", "yml" or "toml", see WithConfig.
+// Includes are not supported, environment overrides are applied by Default as usual.
+func ParseConfig(format string, data []byte) (ConfigMap, error) {
+	parse := configFormats["."+strings.ToLower(format)]
+	if parse == nil {
+		return ConfigMap{}, fmt.Errorf("unknown config format %q, use json, yaml, yml or toml", format)
+	}
+
+	tree, err := parse(data)
+	if err != nil {
+		return ConfigMap{}, err
+	}
+
+	if tree == nil {
+		tree = make(map[string]interface{})
+	}
+	return (&configLoader{tree: tree}).configMap()
+}
+
+// readConfigFile parses file in format detected by its extension into generic tree
+func readConfigFile(filename string) (map[string]interface{}, error) {
+	parse := configFormats[strings.ToLower(filepath.Ext(filename))]
+	if parse == nil {
+		return nil, fmt.Errorf("%s: unknown config format, use .json, .yaml, .yml or .toml", filename)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	if tree == nil {
+		tree = make(map[string]interface{})
+	}
+	return tree, nil
+}
+
+// findConfigFile returns filename if it exists, otherwise the same file with another
+// supported extension, so -config pattern doesn't have to be changed for yaml or toml files
+func findConfigFile(filename string) (string, bool) {
+	if _, err := os.Stat(filename); err == nil {
+		return filename, true
+	}
+
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	for _, ext := range []string{".json", ".yaml", ".yml", ".toml"} {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext, true
+		}
+	}
+
+	return filename, false
+}
+
+// parseJSONConfig keeps numbers as they are written
+func parseJSONConfig(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var tree map[string]interface{}
+	err := decoder.Decode(&tree)
+	return tree, err
+}
+
+func parseYAMLConfig(data []byte) (map[string]interface{}, error) {
+	var tree map[string]interface{}
+	err := yaml.Unmarshal(data, &tree)
+	return tree, err
+}
+
+func parseTOMLConfig(data []byte) (map[string]interface{}, error) {
+	var tree map[string]interface{}
+	err := toml.Unmarshal(data, &tree)
+	return tree, err
+}
+
+// configIncludes returns include directive given as a single path or a list
+func configIncludes(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+
+	case string:
+		return []string{v}, nil
+
+	case []interface{}:
+		includes := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s should be a list of paths", includeKey)
+			}
+			includes[i] = s
+		}
+		return includes, nil
+	}
+
+	return nil, fmt.Errorf("%s should be a path or a list of paths", includeKey)
+}
+
+// mergeConfigTree merges src into dst. Sections are merged recursively,
+// other values are replaced and null removes the key.
+func mergeConfigTree(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if value == nil {
+			delete(dst, key)
+			continue
+		}
+
+		srcSection, srcIsSection := value.(map[string]interface{})
+		if !srcIsSection {
+			dst[key] = value
+			continue
+		}
+
+		dstSection, dstIsSection := dst[key].(map[string]interface{})
+		if !dstIsSection {
+			dstSection = make(map[string]interface{})
+			dst[key] = dstSection
+		}
+		mergeConfigTree(dstSection, srcSection)
+	}
+}
+
+// Files returns config files merged into the config, in order.
+func (c ConfigMap) Files() []string {
+	return c.current().files
+}
+
+// MarshalJSON returns merged config tree, so the effective config can be inspected
+// with json.MarshalIndent(app.Config, "", "  "). Environment overrides are not included,
+// and values of keys like "password" or "clientSecret" are redacted.
+func (c ConfigMap) MarshalJSON() ([]byte, error) {
+	c = c.current()
+	if c.data == nil {
+		return []byte("{}"), nil
+	}
+
+	data, err := json.Marshal(c.data)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var tree interface{}
+	if err = decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(redactConfigTree(tree))
+}
+
+// String returns redacted config tree, so the config can be logged safely.
+func (c ConfigMap) String() string {
+	data, err := c.MarshalJSON()
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
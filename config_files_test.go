@@ -0,0 +1,138 @@
+package floki
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"base.json": `{
+			"name": "app",
+			"db": {"host": "localhost", "port": 5432, "pool": {"min": 1, "max": 10}},
+			"hosts": ["a", "b"],
+			"debug": true
+		}`,
+		"production.json": `{
+			"include": "db.json",
+			"db": {"pool": {"max": 50}},
+			"hosts": ["c"],
+			"debug": null
+		}`,
+		"db.json":    `{"db": {"host": "db.internal", "port": 6432}}`,
+		"local.json": `{"db": {"port": 7432}}`,
+	})
+
+	c, err := loadConfigFiles(filepath.Join(dir, "%s.json"), "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := json.Marshal(c)
+
+	var merged, expected interface{}
+	json.Unmarshal(data, &merged)
+	json.Unmarshal([]byte(`{
+		"name": "app",
+		"db": {"host": "db.internal", "port": 7432, "pool": {"min": 1, "max": 50}},
+		"hosts": ["c"]
+	}`), &expected)
+
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("unexpected merged config %s", data)
+	}
+
+	var files []string
+	for _, file := range c.Files() {
+		files = append(files, filepath.Base(file))
+	}
+
+	if !reflect.DeepEqual(files, []string{"base.json", "db.json", "production.json", "local.json"}) {
+		t.Errorf("unexpected merge order %v", files)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"base.json":        `{"name": "app"}`,
+		"development.json": `{"include": ["a.json"]}`,
+		"a.json":           `{"include": "b.json"}`,
+		"b.json":           `{"include": "a.json"}`,
+	})
+
+	_, err := loadConfigFiles(filepath.Join(dir, "%s.json"), "development")
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("include cycle should be detected, got %v", err)
+	}
+
+	_, err = loadConfigFiles(filepath.Join(dir, "%s.json"), "production")
+	if err == nil {
+		t.Error("environment config file is required")
+	}
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"base.yaml": `
+# shared settings
+name: app
+db:
+  host: localhost
+  port: 5432
+hosts: [a, b]
+timeout: 30s
+`,
+		"production.toml": `
+# production overrides
+include = "mail.json"
+timeout = "10s"
+
+[db]
+host = "db.internal"
+`,
+		"mail.json": `{"mail": {"from": "noreply@example.org"}}`,
+		"app.ini":   `name = app`,
+	})
+
+	c, err := loadConfigFiles(filepath.Join(dir, "%s.json"), "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := c.Str("name", ""); name != "app" {
+		t.Errorf("unexpected name %s", name)
+	}
+	if host := c.Str("db.host", ""); host != "db.internal" {
+		t.Errorf("unexpected host %s", host)
+	}
+	if port := c.Map("db").Int("port", 0); port != 5432 {
+		t.Errorf("unexpected port %d", port)
+	}
+	if hosts := c.Strings("hosts", nil); !reflect.DeepEqual(hosts, []string{"a", "b"}) {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+	if timeout := c.Str("timeout", ""); timeout != "10s" {
+		t.Errorf("unexpected timeout %s", timeout)
+	}
+	if from := c.Str("mail.from", ""); from != "noreply@example.org" {
+		t.Errorf("unexpected mail sender %s", from)
+	}
+
+	if _, err = loadConfigFiles(filepath.Join(dir, "app.ini"), ""); err == nil {
+		t.Error("unknown format should fail")
+	}
+}
@@ -0,0 +1,228 @@
+package floki
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// configStore holds data of reloadable config. Data is replaced atomically,
+	// so requests being handled during reload see either old or new config, never a mix.
+	configStore struct {
+		snapshot atomic.Pointer[configSnapshot]
+
+		// replaceMu keeps watchers of concurrent reloads from being called out of order
+		replaceMu sync.Mutex
+
+		mu         sync.Mutex
+		watchers   []configWatcher
+		validators []func(c ConfigMap) error
+	}
+
+	configSnapshot struct {
+		data  map[string]*json.RawMessage
+		files []string
+
+		// sections decoded by path, so reads through sections don't decode them every time
+		sections sync.Map
+	}
+
+	configWatcher struct {
+		key string
+		fn  func(old, new ConfigMap)
+	}
+)
+
+// current returns config with data of the latest reload. Sections returned by Map
+// share the store with the root config, so they see reloaded values as well.
+func (c ConfigMap) current() ConfigMap {
+	if c.store != nil {
+		snapshot := c.store.snapshot.Load()
+		c.data = snapshot.section(c.path)
+		c.files = snapshot.files
+	}
+	return c
+}
+
+// section returns data of the section at path, empty if the section is missing
+func (s *configSnapshot) section(path []string) map[string]*json.RawMessage {
+	if len(path) == 0 {
+		return s.data
+	}
+
+	key := strings.Join(path, "\x00")
+	if data, ok := s.sections.Load(key); ok {
+		return data.(map[string]*json.RawMessage)
+	}
+
+	data := s.data
+	for _, name := range path {
+		next := make(map[string]*json.RawMessage)
+		if raw := data[name]; raw != nil {
+			json.Unmarshal(*raw, &next)
+		}
+		data = next
+	}
+
+	s.sections.Store(key, data)
+	return data
+}
+
+// reloadable makes config data replaceable. Copies of the config made
+// after this call see the data of the latest reload.
+func (c *ConfigMap) reloadable() *configStore {
+	if c.store == nil {
+		c.store = &configStore{}
+		c.store.snapshot.Store(&configSnapshot{data: c.data, files: c.files})
+	}
+	return c.store
+}
+
+// Watch calls fn with configs before and after reload when value of the key has changed.
+// Dotted keys like "limits.api" are supported. Sections are compared as a whole,
+// values overridden by environment variables are not compared. On a section returned
+// by Map, key is relative to the section, while fn gets the whole configs.
+//     app.Config.Watch("rateLimit", func(old, new floki.ConfigMap) {
+//         limiter.SetLimit(new.Int("rateLimit", 100))
+//     })
+func (c *ConfigMap) Watch(key string, fn func(old, new ConfigMap)) {
+	store := c.reloadable()
+
+	store.mu.Lock()
+	store.watchers = append(store.watchers, configWatcher{strings.Join(c.childPath(key), "."), fn})
+	store.mu.Unlock()
+}
+
+// AddValidator adds function which checks config before it's applied on reload.
+// When any validator fails, reloaded config is rejected and current one is kept.
+// Validator added to a section returned by Map gets the same section of reloaded config.
+func (c *ConfigMap) AddValidator(fn func(c ConfigMap) error) {
+	store := c.reloadable()
+
+	if len(c.path) > 0 {
+		key, sectionFn := strings.Join(c.path, "."), fn
+		fn = func(next ConfigMap) error {
+			return sectionFn(next.Map(key))
+		}
+	}
+
+	store.mu.Lock()
+	store.validators = append(store.validators, fn)
+	store.mu.Unlock()
+}
+
+// replace validates next config, swaps it in and notifies watchers of changed keys.
+// Validators and watchers are called without the lock held, so they can add watchers
+// and validators.
+func (c *ConfigMap) replace(next ConfigMap) error {
+	store := c.reloadable()
+
+	store.replaceMu.Lock()
+	defer store.replaceMu.Unlock()
+
+	next.env = c.env
+	next.store = nil
+
+	if errs := store.validate(next); len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	store.mu.Lock()
+
+	old := c.current()
+	old.store = nil
+
+	store.snapshot.Store(&configSnapshot{data: next.data, files: next.files})
+	watchers := append([]configWatcher(nil), store.watchers...)
+
+	store.mu.Unlock()
+
+	for _, watcher := range watchers {
+		if !bytes.Equal(old.raw(watcher.key), next.raw(watcher.key)) {
+			watcher.fn(old, next)
+		}
+	}
+
+	return nil
+}
+
+// validate runs validators added with AddValidator. The lock is not held while they run,
+// so they can add validators themselves.
+func (s *configStore) validate(c ConfigMap) []error {
+	s.mu.Lock()
+	validators := append([]func(c ConfigMap) error(nil), s.validators...)
+	s.mu.Unlock()
+
+	var errs []error
+	for _, validate := range validators {
+		if err := validate(c); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// raw returns json of the key, or nil if the key is not set
+func (c ConfigMap) raw(key string) []byte {
+	c, key = c.current().section(key)
+
+	if raw := c.data[key]; raw != nil {
+		return *raw
+	}
+	return nil
+}
+
+// ReloadConfig loads config files again and replaces current config if they match
+// registered schema, see ConfigSection, and pass validators.
+// Watchers of changed keys and OnReload hooks are called then. Current config is kept
+// when files can't be loaded or validation fails.
+//
+// Config is reloaded on SIGHUP, and when "configWatch" is true, also when config files change.
+// Settings applied at startup, like log level, trusted proxies, listeners and
+// server timeouts, need a restart to change.
+func (f *Floki) ReloadConfig() error {
+	if len(f.Config.Files()) == 0 {
+		return errors.New("config was not loaded from files")
+	}
+
+	next, err := loadConfigFiles(f.configFile, f.Env)
+	if err != nil {
+		return err
+	}
+
+	next.env = f.Config.env
+	if err = f.validateSchema(next); err != nil {
+		return err
+	}
+
+	if err = f.Config.replace(next); err != nil {
+		return err
+	}
+
+	f.log.Info("config reloaded", "files", next.Files())
+
+	if err = f.runHooks(stageReload); err != nil {
+		return fmt.Errorf("config was applied, but reload hooks failed: %v", err)
+	}
+	return nil
+}
+
+// watchConfigFiles reloads config when files in their directories change
+func (f *Floki) watchConfigFiles() {
+	var dirs []string
+	for _, file := range f.Config.Files() {
+		dirs = append(dirs, filepath.Dir(file))
+	}
+
+	f.watchDirs("config files", dirs, func() {
+		if err := f.ReloadConfig(); err != nil {
+			f.log.Error("config reload failed", "error", err)
+		}
+	})
+}
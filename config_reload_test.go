@@ -0,0 +1,175 @@
+package floki
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadConfig(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"base.json":   `{"name": "app"}`,
+		Dev + ".json": `{"rateLimit": 100}`,
+	})
+
+	f := newHooksApp(WithConfigFile(filepath.Join(dir, "%s.json")))
+
+	var err error
+	if f.Config, err = loadConfigFiles(f.configFile, f.Env); err != nil {
+		t.Fatal(err)
+	}
+	f.Config.reloadable()
+
+	// modules keep copies of config, they should see reloaded values too
+	moduleConfig := f.Config
+
+	var oldLimit, newLimit int
+	f.Config.Watch("rateLimit", func(old, new ConfigMap) {
+		oldLimit, newLimit = old.Int("rateLimit", 0), new.Int("rateLimit", 0)
+	})
+	f.Config.Watch("name", func(old, new ConfigMap) {
+		t.Error("watcher of unchanged key should not be called")
+	})
+	f.Config.AddValidator(func(c ConfigMap) error {
+		if c.Int("rateLimit", 0) < 0 {
+			return errors.New("rateLimit can't be negative")
+		}
+		return nil
+	})
+
+	reloaded := false
+	f.OnReload(func(ctx context.Context, app *Floki) error {
+		reloaded = true
+		return nil
+	})
+
+	ioutil.WriteFile(filepath.Join(dir, Dev+".json"), []byte(`{"rateLimit": 200}`), 0644)
+	if err = f.ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	if oldLimit != 100 || newLimit != 200 {
+		t.Errorf("watcher should get old and new values, got %d and %d", oldLimit, newLimit)
+	}
+	if limit := moduleConfig.Int("rateLimit", 0); limit != 200 {
+		t.Errorf("copy of config should see new value, got %d", limit)
+	}
+	if !reloaded {
+		t.Error("reload hooks should be called")
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, Dev+".json"), []byte(`{"rateLimit": -1}`), 0644)
+	if err = f.ReloadConfig(); err == nil {
+		t.Error("invalid config should be rejected")
+	}
+	if limit := f.Config.Int("rateLimit", 0); limit != 200 {
+		t.Errorf("current config should be kept, got %d", limit)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, Dev+".json"), []byte(`{"rateLimit": `), 0644)
+	if err = f.ReloadConfig(); err == nil {
+		t.Error("broken config file should be rejected")
+	}
+	if name := f.Config.Str("name", ""); name != "app" {
+		t.Errorf("current config should be kept, got %s", name)
+	}
+}
+
+func TestConfigWatcherAddsWatcher(t *testing.T) {
+	c := testConfig(t, `{"rateLimit": 100}`)
+
+	added := false
+	c.Watch("rateLimit", func(old, new ConfigMap) {
+		// watchers run without the lock of the store
+		c.Watch("name", func(old, new ConfigMap) {})
+		c.AddValidator(func(c ConfigMap) error { return nil })
+		added = true
+	})
+
+	next := testConfig(t, `{"rateLimit": 200}`)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.replace(next)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil || !added {
+			t.Errorf("watcher should be called, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("replace deadlocked")
+	}
+}
+
+func TestConfigValidatorAddsValidator(t *testing.T) {
+	c := testConfig(t, `{"rateLimit": 100}`)
+
+	c.AddValidator(func(next ConfigMap) error {
+		// validators run without the lock of the store
+		c.AddValidator(func(c ConfigMap) error { return nil })
+		return nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.replace(testConfig(t, `{"rateLimit": 200}`))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("replace deadlocked")
+	}
+}
+
+func TestConfigSectionsSeeReloadedValues(t *testing.T) {
+	c := testConfig(t, `{"db": {"primary": {"host": "db1"}}, "cache": {"size": 10}}`)
+	c.reloadable()
+
+	// modules keep sections they were configured with
+	primary := c.Map("db.primary")
+	cache := c.Map("cache")
+
+	var validated string
+	primary.AddValidator(func(section ConfigMap) error {
+		validated = section.Str("host", "")
+		return nil
+	})
+
+	changed := false
+	cache.Watch("size", func(old, new ConfigMap) {
+		changed = old.Int("cache.size", 0) == 10 && new.Int("cache.size", 0) == 20
+	})
+
+	if err := c.replace(testConfig(t, `{"db": {"primary": {"host": "db2"}}, "cache": {"size": 20}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if host := primary.Str("host", ""); host != "db2" {
+		t.Errorf("section should see reloaded value, got %s", host)
+	}
+	if size := cache.Int("size", 0); size != 20 {
+		t.Errorf("section should see reloaded value, got %d", size)
+	}
+	if validated != "db2" {
+		t.Errorf("validator of section should get reloaded section, got %q", validated)
+	}
+	if !changed {
+		t.Error("watcher of section key should be called")
+	}
+
+	if err := c.replace(testConfig(t, `{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if host := primary.Str("host", "none"); host != "none" {
+		t.Errorf("removed section should be empty, got %s", host)
+	}
+}
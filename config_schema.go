@@ -0,0 +1,240 @@
+package floki
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+type (
+	// ConfigValidator is implemented by config section structs which check
+	// relations between values after they are decoded.
+	ConfigValidator interface {
+		Validate() error
+	}
+
+	configSection struct {
+		key  string
+		typ  reflect.Type
+		name string
+	}
+
+	// flokiConfig describes config keys used by the framework itself
+	flokiConfig struct {
+		LogLevel           string
+		LogFormat          string `default:"text"`
+		LogFile            string
+		LogMaxSizeMB       int
+		LogRotateInterval  time.Duration
+		LogMaxBackups      int
+		LogMaxAge          time.Duration
+		LogCompress        bool
+		AccessLog          bool
+		AccessLogFormat    string
+		TrustedProxies     []string
+		ProxyHeader        string `default:"X-Forwarded-For"`
+		TimeZone           string
+		EnvPrefix          string
+		EnvNaming          string `default:"snake"`
+		ConfigWatch        bool
+		DrainTimeout       time.Duration
+		DrainDelay         time.Duration
+		RestartTimeout     time.Duration
+		HookTimeout        time.Duration
+		HealthCheckTimeout time.Duration
+		HealthPath         string
+		ReadyPath          string
+		HealthListeners    []string
+		ReadTimeout        time.Duration
+		ReadHeaderTimeout  time.Duration
+		WriteTimeout       time.Duration
+		IdleTimeout        time.Duration
+		MaxHeaderBytes     ByteSize
+		KeepAlive          bool
+		H2C                bool   `config:"h2c"`
+		TLSCert            string `config:"tlsCert"`
+		TLSKey             string `config:"tlsKey"`
+		TLSWatch           bool   `config:"tlsWatch"`
+		TLSRedirectAddr    string `config:"tlsRedirectAddr"`
+		SocketMode         string
+		PidFile            string
+		EnableProfiling    bool
+	}
+)
+
+// ConfigSection registers schema of config section key, which is checked before the server
+// starts, with -check-config argument and before reloaded config is applied. Schema is a struct
+// decoded with DecodeAll, so its tags describe defaults and required keys. When the struct
+// implements ConfigValidator, Validate is called after decoding. Empty key describes
+// the root of the config. Modules register their sections, so all problems are found at once:
+//     app.ConfigSection("db", DBConfig{})
+func (f *Floki) ConfigSection(key string, schema interface{}) {
+	typ := reflect.TypeOf(schema)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("config schema of %q should be a struct, got %T", key, schema))
+	}
+
+	name := key
+	if name == "" {
+		name = "config"
+	}
+
+	f.configSchema = append(f.configSchema, configSection{key, typ, name})
+}
+
+// ValidateConfig checks current config against registered schema and validators added
+// with Config.AddValidator. It returns ConfigErrors listing all problems.
+func (f *Floki) ValidateConfig() error {
+	var errs ConfigErrors
+
+	if err := f.validateSchema(f.Config); err != nil {
+		errs = append(errs, err.(ConfigErrors)...)
+	}
+
+	if f.Config.store != nil {
+		for _, err := range f.Config.store.validate(f.Config.current()) {
+			errs = append(errs, &ConfigError{"config", err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateSchema decodes sections of c into registered schema structs and validates them
+func (f *Floki) validateSchema(c ConfigMap) error {
+	var errs ConfigErrors
+
+	for _, section := range f.configSchema {
+		value := reflect.New(section.typ)
+
+		conf := c
+		if section.key != "" {
+			conf = c.Map(section.key)
+		}
+
+		err := conf.DecodeAll(value.Interface())
+		if decodeErrs, ok := err.(ConfigErrors); ok {
+			errs = append(errs, decodeErrs...)
+			continue
+		}
+
+		if err == nil {
+			if validator, ok := value.Interface().(ConfigValidator); ok {
+				err = validator.Validate()
+			}
+		}
+
+		// each of joined errors is a separate problem
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				errs = append(errs, &ConfigError{section.name, e})
+			}
+		} else if err != nil {
+			errs = append(errs, &ConfigError{section.name, err})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkConfig validates config and configure hooks for -check-config argument.
+// It prints all problems and returns exit code.
+func (f *Floki) checkConfig(out io.Writer) int {
+	var errs ConfigErrors
+	if err := f.ValidateConfig(); err != nil {
+		errs = err.(ConfigErrors)
+	}
+
+	if err := f.runHooks(stageConfigure); err != nil {
+		errs = append(errs, &ConfigError{"hooks", err})
+	}
+
+	if len(errs) == 0 {
+		fmt.Fprintf(out, "config of %s environment is valid: %v\n", f.Env, f.Config.Files())
+		return 0
+	}
+
+	fmt.Fprintf(out, "config of %s environment has %d problems: %v\n", f.Env, len(errs), f.Config.Files())
+	for _, err := range errs {
+		fmt.Fprintln(out, "  "+err.Error())
+	}
+
+	return 1
+}
+
+// runConfigCheck checks config when the application is started with -check-config, see WithArgs.
+// It returns ErrHandled when config is valid, so the server is not started.
+func (f *Floki) runConfigCheck() error {
+	if !f.checkConfigOnly {
+		return nil
+	}
+
+	if f.checkConfig(os.Stdout) != 0 {
+		return fmt.Errorf("config of %s environment is invalid", f.Env)
+	}
+	return ErrHandled
+}
+
+// Validate checks values which can't be described by tags
+func (c *flokiConfig) Validate() error {
+	var errs []error
+
+	if c.LogLevel != "" {
+		if _, err := ParseLogLevel(c.LogLevel); err != nil {
+			errs = append(errs, fmt.Errorf("logLevel: %v", err))
+		}
+	}
+
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("logFormat: should be text or json, got %q", c.LogFormat))
+	}
+
+	if err := ValidateAccessLogFormat(c.AccessLogFormat); err != nil {
+		errs = append(errs, fmt.Errorf("accessLogFormat: %v", err))
+	}
+
+	if envNamings[c.EnvNaming] == nil {
+		errs = append(errs, fmt.Errorf("envNaming: should be snake or exact, got %q", c.EnvNaming))
+	}
+
+	if _, err := time.LoadLocation(c.TimeZone); err != nil {
+		errs = append(errs, fmt.Errorf("timeZone: %v", err))
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if _, err := ParseNetwork(proxy); err != nil {
+			errs = append(errs, fmt.Errorf("trustedProxies: %v", err))
+		}
+	}
+
+	if !proxyHeaders[http.CanonicalHeaderKey(c.ProxyHeader)] {
+		errs = append(errs, fmt.Errorf("proxyHeader: should be X-Forwarded-For, Forwarded or X-Real-IP, got %q", c.ProxyHeader))
+	}
+
+	if c.SocketMode != "" {
+		if _, err := strconv.ParseUint(c.SocketMode, 8, 32); err != nil {
+			errs = append(errs, fmt.Errorf("socketMode: should be octal like 0660, got %q", c.SocketMode))
+		}
+	}
+
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, errors.New("tlsCert and tlsKey should be set together"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,89 @@
+package floki
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testMailConfig struct {
+	Host string `required:"true"`
+	Port int    `default:"25"`
+	From string
+}
+
+func (c *testMailConfig) Validate() error {
+	if !strings.Contains(c.From, "@") {
+		return errors.New("from should be an email address")
+	}
+	return nil
+}
+
+func TestCheckConfig(t *testing.T) {
+	f := newHooksApp()
+	f.Config = testConfig(t, `{
+		"logLevel": "verbose",
+		"trustedProxies": ["10.0.0.0/8", "proxy"],
+		"mail": {"port": "smtp", "from": "noreply"},
+		"db": {"port": 5432}
+	}`)
+
+	f.ConfigSection("mail", testMailConfig{})
+	f.ConfigSection("db", &testDBConfig{})
+
+	var out bytes.Buffer
+	if code := f.checkConfig(&out); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+
+	problems := []string{
+		"config: logLevel",
+		"config: trustedProxies",
+		"mail.host: required key is missing",
+		"mail.port",
+		"db.dsn: required key is missing",
+	}
+
+	for _, problem := range problems {
+		if !strings.Contains(out.String(), problem) {
+			t.Errorf("output should report %q:\n%s", problem, out.String())
+		}
+	}
+
+	f.Config = testConfig(t, `{
+		"mail": {"host": "localhost", "from": "noreply"},
+		"db": {"dsn": "postgres://localhost"}
+	}`)
+
+	out.Reset()
+	if code := f.checkConfig(&out); code != 1 || !strings.Contains(out.String(), "mail: from should be an email address") {
+		t.Errorf("section validator should be called, got %d:\n%s", code, out.String())
+	}
+
+	f.Config = testConfig(t, `{
+		"mail": {"host": "localhost", "from": "noreply@example.org"},
+		"db": {"dsn": "postgres://localhost"}
+	}`)
+
+	out.Reset()
+	if code := f.checkConfig(&out); code != 0 {
+		t.Errorf("valid config should pass, got %d:\n%s", code, out.String())
+	}
+}
+
+func TestInvalidConfigAbortsStart(t *testing.T) {
+	f := newHooksApp()
+	f.Config = testConfig(t, `{"logFormat": "jsn"}`)
+
+	f.OnConfigure(func(ctx context.Context, app *Floki) error {
+		t.Error("configure hook should not be called with invalid config")
+		return nil
+	})
+
+	err := f.serve(freeAddr(t), f, "", nil)
+	if _, ok := err.(ConfigErrors); !ok || !strings.Contains(err.Error(), "logFormat") {
+		t.Errorf("expected ConfigErrors, got %v", err)
+	}
+}
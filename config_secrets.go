@@ -0,0 +1,187 @@
+package floki
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Secret is a config value which must not show up in logs. It's formatted and
+// marshaled as "[redacted]", use string(secret) to get the value.
+type Secret string
+
+const (
+	// MasterKeyEnv is environment variable with base64 encoded 32 byte key used to decrypt "enc:" values
+	MasterKeyEnv = "FLOKI_MASTER_KEY"
+
+	secretFilePrefix      = "file:"
+	secretEncryptedPrefix = "enc:"
+	redacted              = "[redacted]"
+)
+
+// sensitiveKeys are parts of config and log keys whose values are redacted
+var sensitiveKeys = []string{"password", "passwd", "secret", "token", "apikey", "api_key", "privatekey", "private_key", "credential"}
+
+func (s Secret) String() string {
+	return redacted
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(redacted)
+}
+
+// Secret returns string value of the key with secret reference resolved. Values can be given as:
+//     "file:/run/secrets/db_password" - content of the file without trailing newline
+//     "enc:..."                       - encrypted with master key from FLOKI_MASTER_KEY,
+//                                       see "encrypt" command
+// Other values are returned as they are. References are resolved only here and in decoded
+// fields of Secret type or tagged with secret:"true", Str returns them as they are,
+// so values like "file:app.db" DSN are not mistaken for secrets.
+func (c ConfigMap) Secret(key string) (Secret, error) {
+	var s string
+	found, err := c.lookup(key, &s)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", key, err)
+	}
+
+	if !found {
+		return "", fmt.Errorf("%s: %v", key, errMissingKey)
+	}
+
+	if s, err = resolveSecret(s); err != nil {
+		return "", fmt.Errorf("%s: %v", key, err)
+	}
+
+	return Secret(s), nil
+}
+
+// resolveSecret returns content of secret file or decrypted value of secret reference.
+// Other values are returned as they are.
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretFilePrefix):
+		data, err := ioutil.ReadFile(strings.TrimPrefix(value, secretFilePrefix))
+		if err != nil {
+			return "", fmt.Errorf("can't read secret: %v", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case strings.HasPrefix(value, secretEncryptedPrefix):
+		key, err := masterKey()
+		if err != nil {
+			return "", err
+		}
+		return DecryptSecret(value, key)
+	}
+
+	return value, nil
+}
+
+// masterKey reads key for encrypted values from environment
+func masterKey() ([]byte, error) {
+	value := os.Getenv(MasterKeyEnv)
+	if value == "" {
+		return nil, fmt.Errorf("%s is not set, can't decrypt config value", MasterKeyEnv)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s should be base64 encoded 32 byte key, see genkey command", MasterKeyEnv)
+	}
+
+	return key, nil
+}
+
+// GenerateMasterKey returns new random key encoded for FLOKI_MASTER_KEY.
+func GenerateMasterKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// EncryptSecret encrypts value with AES-256-GCM and returns it as "enc:..." config value.
+func EncryptSecret(value string, key []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(value), nil)
+	return secretEncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret decrypts "enc:..." config value created by EncryptSecret.
+func DecryptSecret(value string, key []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretEncryptedPrefix))
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("can't decrypt value, wrong master key or corrupted value")
+	}
+
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// isSensitiveKey checks whether values of the config or log key should be redacted
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactConfigTree replaces values of sensitive keys in generic config tree
+func redactConfigTree(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if _, isSection := item.(map[string]interface{}); isSensitiveKey(key) && !isSection {
+				v[key] = redacted
+			} else {
+				v[key] = redactConfigTree(item)
+			}
+		}
+
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactConfigTree(item)
+		}
+	}
+
+	return value
+}
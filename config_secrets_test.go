@@ -0,0 +1,126 @@
+package floki
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setTestMasterKey(t *testing.T) []byte {
+	key := bytes.Repeat([]byte{7}, 32)
+	t.Setenv(MasterKeyEnv, base64.StdEncoding.EncodeToString(key))
+	return key
+}
+
+func TestConfigSecrets(t *testing.T) {
+	key := setTestMasterKey(t)
+
+	encrypted, err := EncryptSecret("s3cret", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secretFile := filepath.Join(t.TempDir(), "db_password")
+	ioutil.WriteFile(secretFile, []byte("from-file\n"), 0600)
+
+	c := testConfig(t, `{
+		"oauth": {"google": {"clientSecret": "`+encrypted+`"}},
+		"db": {"password": "file:`+secretFile+`"},
+		"broken": "enc:AAAA",
+		"dsn": "file:app.db?cache=shared",
+		"name": "app"
+	}`)
+
+	if secret, err := c.Secret("oauth.google.clientSecret"); err != nil || string(secret) != "s3cret" {
+		t.Errorf("encrypted value should be decrypted, got %q, %v", string(secret), err)
+	}
+
+	// only Secret and secret fields resolve references
+	if dsn := c.Str("dsn", ""); dsn != "file:app.db?cache=shared" {
+		t.Errorf("plain file: value should pass through, got %q", dsn)
+	}
+
+	if value := c.Str("oauth.google.clientSecret", ""); value != encrypted {
+		t.Errorf("Str should not decrypt values, got %q", value)
+	}
+
+	secret, err := c.Secret("db.password")
+	if err != nil || string(secret) != "from-file" {
+		t.Errorf("secret file should be read, got %q, %v", string(secret), err)
+	}
+
+	if _, err = c.Secret("broken"); err == nil {
+		t.Error("malformed encrypted value should fail")
+	}
+
+	if _, err = c.Secret("missing"); err == nil {
+		t.Error("missing secret should fail")
+	}
+
+	var db struct {
+		Password Secret `config:"password"`
+		Raw      string `config:"password"`
+		Tagged   string `config:"password" secret:"true"`
+	}
+	if err = c.Decode("db", &db); err != nil || string(db.Password) != "from-file" || db.Tagged != "from-file" {
+		t.Errorf("decoded secret should be resolved, got %q, %q, %v", string(db.Password), db.Tagged, err)
+	}
+
+	if db.Raw != "file:"+secretFile {
+		t.Errorf("plain string field should not be resolved, got %q", db.Raw)
+	}
+
+	var app struct {
+		DSN string `config:"dsn"`
+	}
+	if err = c.DecodeAll(&app); err != nil || app.DSN != "file:app.db?cache=shared" {
+		t.Errorf("plain file: value should be decoded as it is, got %q, %v", app.DSN, err)
+	}
+}
+
+func TestSecretRedaction(t *testing.T) {
+	c := testConfig(t, `{
+		"name": "app",
+		"db": {"password": "plain", "port": 5432},
+		"oauth": {"google": {"clientSecret": "abc", "clientId": "id"}}
+	}`)
+
+	dump, _ := json.Marshal(c)
+	if bytes.Contains(dump, []byte("plain")) || bytes.Contains(dump, []byte("abc")) {
+		t.Errorf("config dump should be redacted: %s", dump)
+	}
+	if !bytes.Contains(dump, []byte(`"clientId":"id"`)) || !bytes.Contains(dump, []byte(`"port":5432`)) {
+		t.Errorf("other values should be kept: %s", dump)
+	}
+
+	var buf bytes.Buffer
+	log := NewLog(&buf, LevelInfo, nil)
+	log.Info("connecting", "password", "plain", "secret", Secret("abc"), "config", c)
+
+	if strings.Contains(buf.String(), "plain") || strings.Contains(buf.String(), "abc") {
+		t.Errorf("log should be redacted: %s", buf.String())
+	}
+}
+
+func TestEncryptCommand(t *testing.T) {
+	key := setTestMasterKey(t)
+
+	var out bytes.Buffer
+	handled, err := runCommand([]string{"encrypt"}, strings.NewReader("value\n"), &out)
+	if !handled || err != nil {
+		t.Fatal(handled, err)
+	}
+
+	value, err := DecryptSecret(strings.TrimSpace(out.String()), key)
+	if err != nil || value != "value" {
+		t.Errorf("encrypted value should be decrypted, got %q, %v", value, err)
+	}
+
+	if handled, _ = runCommand([]string{"serve"}, nil, &out); handled {
+		t.Error("unknown command should not be handled")
+	}
+}
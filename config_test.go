@@ -0,0 +1,165 @@
+package floki
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testConfig(t *testing.T, data string) ConfigMap {
+	var c ConfigMap
+	if err := json.Unmarshal([]byte(data), &c.data); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestConfigGetters(t *testing.T) {
+	c := testConfig(t, `{
+		"ratio": 0.75,
+		"ports": [80, 443],
+		"maxBody": "10MB",
+		"bufferSize": 4096,
+		"since": "2024-03-01T10:00:00Z",
+		"until": "2024-12-31",
+		"invalid": "abc"
+	}`)
+
+	if ratio := c.Float64("ratio", 0); ratio != 0.75 {
+		t.Errorf("unexpected ratio %v", ratio)
+	}
+	if ports := c.Ints("ports", nil); !reflect.DeepEqual(ports, []int{80, 443}) {
+		t.Errorf("unexpected ports %v", ports)
+	}
+	if size := c.Bytes("maxBody", 0); size != 10<<20 {
+		t.Errorf("unexpected size %d", size)
+	}
+	if size := c.Bytes("bufferSize", 0); size != 4096 {
+		t.Errorf("unexpected size %d", size)
+	}
+	if since := c.Time("since", time.Time{}); !since.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time %v", since)
+	}
+	if until := c.Time("until", time.Time{}); !until.Equal(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected date %v", until)
+	}
+
+	// invalid and missing values fall back to defaults
+	if ratio := c.Float64("invalid", 1.5); ratio != 1.5 {
+		t.Errorf("invalid float should return default, got %v", ratio)
+	}
+	if size := c.Bytes("invalid", 1); size != 1 {
+		t.Errorf("invalid size should return default, got %d", size)
+	}
+	if ports := c.Ints("missing", []int{8080}); !reflect.DeepEqual(ports, []int{8080}) {
+		t.Errorf("missing list should return default, got %v", ports)
+	}
+}
+
+func TestConfigGettersFromEnv(t *testing.T) {
+	c := testConfig(t, `{}`)
+	c.SetEnv("APP_", nil)
+
+	t.Setenv("APP_MAX_BODY", "512KiB")
+	t.Setenv("APP_PORTS", "80,443")
+	t.Setenv("APP_RATIO", "0.5")
+
+	if size := c.Bytes("maxBody", 0); size != 512<<10 {
+		t.Errorf("unexpected size %d", size)
+	}
+	if ports := c.Ints("ports", nil); !reflect.DeepEqual(ports, []int{80, 443}) {
+		t.Errorf("unexpected ports %v", ports)
+	}
+	if ratio := c.Float64("ratio", 0); ratio != 0.5 {
+		t.Errorf("unexpected ratio %v", ratio)
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	tests := map[string]int64{
+		"100":    100,
+		"100B":   100,
+		"1kb":    1 << 10,
+		"1.5 MB": 3 << 19,
+		"2GiB":   2 << 30,
+		"1T":     1 << 40,
+	}
+
+	for s, expected := range tests {
+		if size, err := ParseBytes(s); err != nil || size != expected {
+			t.Errorf("%s: expected %d, got %d, %v", s, expected, size, err)
+		}
+	}
+
+	for _, s := range []string{"", "MB", "10XB", "1.2.3KB"} {
+		if _, err := ParseBytes(s); err == nil {
+			t.Errorf("%q should not be parsed", s)
+		}
+	}
+}
+
+func TestConfigGettersConversionErrors(t *testing.T) {
+	c := testConfig(t, `{
+		"port": "3000",
+		"debug": "yes",
+		"name": 42,
+		"hosts": "a",
+		"ports": ["80"],
+		"timeout": 30,
+		"maxBody": true,
+		"since": 2024
+	}`)
+
+	// every getter returns the default when value has wrong type
+	if port := c.Int("port", 80); port != 80 {
+		t.Errorf("Int: expected default, got %d", port)
+	}
+	if port := c.Float64("port", 80); port != 80 {
+		t.Errorf("Float64: expected default, got %v", port)
+	}
+	if !c.Bool("debug", true) {
+		t.Error("Bool: expected default")
+	}
+	if name := c.Str("name", "app"); name != "app" {
+		t.Errorf("Str: expected default, got %q", name)
+	}
+	if hosts := c.Strings("hosts", []string{"b"}); !reflect.DeepEqual(hosts, []string{"b"}) {
+		t.Errorf("Strings: expected default, got %v", hosts)
+	}
+	if ports := c.Ints("ports", []int{443}); !reflect.DeepEqual(ports, []int{443}) {
+		t.Errorf("Ints: expected default, got %v", ports)
+	}
+	if timeout := c.Duration("timeout", time.Second); timeout != time.Second {
+		t.Errorf("Duration: expected default, got %s", timeout)
+	}
+	if size := c.Bytes("maxBody", 1024); size != 1024 {
+		t.Errorf("Bytes: expected default, got %d", size)
+	}
+	if since := c.Time("since", time.Time{}); !since.IsZero() {
+		t.Errorf("Time: expected default, got %s", since)
+	}
+}
+
+func TestConfigDottedPath(t *testing.T) {
+	c := testConfig(t, `{
+		"db": {"primary": {"host": "db1", "port": 5432}},
+		"tlsCertificates": {"example.org": {"cert": "example.pem"}}
+	}`)
+	c.SetEnv("APP_", nil)
+
+	if host := c.Str("db.primary.host", ""); host != "db1" {
+		t.Errorf("unexpected host %s", host)
+	}
+	if port := c.Map("db.primary").Int("port", 0); port != 5432 {
+		t.Errorf("unexpected port %d", port)
+	}
+	if cert := c.Map("tlsCertificates").Map("example.org").Str("cert", ""); cert != "example.pem" {
+		t.Errorf("keys with dots should be found, got %s", cert)
+	}
+
+	t.Setenv("APP_DB_PRIMARY_HOST", "db2")
+	if host := c.Str("db.primary.host", ""); host != "db2" {
+		t.Errorf("dotted key should be overridden by env, got %s", host)
+	}
+}
@@ -1,6 +1,7 @@
 package floki
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -47,6 +48,9 @@ type (
 		handlers    []HandlerFunc
 		index       int8
 		beforeFuncs []BeforeFunc
+		log         Log
+		requestID   string
+		clientIP    string
 	}
 )
 
@@ -93,41 +97,76 @@ func (c *Context) RewriteURL(newUrl string) {
 	if handle != nil {
 		(handle.(RouteHandler)).HandleWithContext(c, params)
 	} else {
-		c.Logger().Println("ERROR: failed to rewrite URL!")
+		c.Log().Error("failed to rewrite URL", "url", newUrl)
 	}
 }
 
+// Logger returns application *log.Logger. Prefer Log() which carries request fields.
 func (c *Context) Logger() *log.Logger {
 	return c.Floki.logger
 }
 
+// Log returns a child of application Log which adds request method, path and id to every entry.
+func (c *Context) Log() Log {
+	if c.log == nil {
+		c.log = c.Floki.log.With(
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"request_id", c.RequestID(),
+		)
+	}
+	return c.log
+}
+
+// RequestID returns value of X-Request-Id request header. If the header is absent,
+// a random id is generated once per request.
+func (c *Context) RequestID() string {
+	if c.requestID == "" {
+		c.requestID = c.Request.Header.Get("X-Request-Id")
+		if c.requestID == "" {
+			c.requestID = newRequestID()
+		}
+	}
+	return c.requestID
+}
+
 func (c *Context) Param(name string) interface{} {
 	return c.Params.ByName(name)
 }
 
 func (c *Context) Render(tplName string, data Model) {
+	if buf := c.renderTemplate(tplName, data); buf != nil {
+		c.Writer.WriteHeader(200)
+		c.Writer.Write(buf.Bytes())
+	}
+}
+
+// renderTemplate executes template with data populated by context variables.
+// Errors are sent to the client and nil is returned.
+func (c *Context) renderTemplate(tplName string, data Model) *bytes.Buffer {
 	c.Writer.Header().Set("Content-Type", MIMEHTML)
 
 	templates := c.Floki.GetParameter("templates").(map[string]*template.Template)
 	tpl := templates[tplName]
 
-	if tpl != nil {
-		// populate model with context variables
-		for key, value := range c.Keys {
-			data[key] = value
-		}
+	if tpl == nil {
+		c.Log().Warn("template not found", "template", tplName)
+		c.Send(504, fmt.Sprintf("<div>Template not found: <b>%s</b></div>", tplName))
+		return nil
+	}
 
-		c.Writer.WriteHeader(200)
-		err := tpl.Execute(c.Writer, data)
-		if err != nil {
-			c.Send(504, fmt.Sprintf("<div>Error: <b>%s</b></div>", err))
-		}
+	// populate model with context variables
+	for key, value := range c.Keys {
+		data[key] = value
+	}
 
-	} else {
-		c.Logger().Printf("Template %s not found\n", tplName)
-		c.Send(504, fmt.Sprintf("<div>Template not found: <b>%s</b></div>", tplName))
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		c.Send(504, fmt.Sprintf("<div>Error: <b>%s</b></div>", err))
+		return nil
 	}
 
+	return &buf
 }
 
 func (c *Context) RenderTo(writer io.Writer, tplName string, data interface{}) {
@@ -148,7 +187,7 @@ func (c *Context) RenderTo(writer io.Writer, tplName string, data interface{}) {
 		}
 
 	} else {
-		c.Logger().Printf("Template %s not found\n", tplName)
+		c.Log().Warn("template not found", "template", tplName)
 		writer.Write([]byte(fmt.Sprintf("<div>Template not found: <b>%s</b></div>", tplName)))
 	}
 
@@ -1,204 +1,139 @@
 package floki
 
 import (
-	"bitbucket.org/kardianos/osext"
-	"flag"
-	"github.com/facebookgo/grace/gracehttp"
-	"io/ioutil"
-	"log"
-	"net"
+	"crypto/tls"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
-	"strconv"
-	"sync"
 	"syscall"
-	"time"
 )
 
-var httpWg sync.WaitGroup
-var executablePath string
-
-type gracefulListener struct {
-	net.Listener
-	stop    chan error
-	stopped bool
-}
-
-var theListener *gracefulListener
-
-func (gl *gracefulListener) Accept() (c net.Conn, err error) {
-	c, err = gl.Listener.Accept()
-	if err != nil {
-		log.Println("error accepting connection:", err)
-		return
+// serve accepts connections on addr and additional listeners until Shutdown is called.
+// When started by graceful restart, listeners are inherited from the parent process,
+// which is notified once this process is ready. When started by systemd socket activation,
+// passed sockets are used instead of opening new ones.
+func (f *Floki) serve(addr string, handler http.Handler, pidFile string, tlsConfig *tls.Config) error {
+	if err := f.runConfigCheck(); err != nil {
+		return err
 	}
 
-	c = gracefulConn{Conn: c}
-
-	httpWg.Add(1)
-
-	return
-}
-
-func newGracefulListener(l net.Listener) (gl *gracefulListener) {
-	gl = &gracefulListener{Listener: l, stop: make(chan error)}
-	go func() {
-		_ = <-gl.stop
-		gl.stopped = true
-		gl.stop <- gl.Listener.Close()
-	}()
-	return gl
-}
-
-func (gl *gracefulListener) Close() error {
-	if gl.stopped {
-		return syscall.EINVAL
+	// config which would be rejected on reload doesn't start the server either
+	if err := f.ValidateConfig(); err != nil {
+		return err
 	}
-	gl.stop <- nil
-	return <-gl.stop
-}
-
-func (gl *gracefulListener) File() *os.File {
-	tl := gl.Listener.(*net.TCPListener)
-	fl, _ := tl.File()
-	return fl
-}
 
-type gracefulConn struct {
-	net.Conn
-}
-
-func (w gracefulConn) Close() error {
-	httpWg.Done()
-	return w.Conn.Close()
-}
+	if err := f.configureListeners(); err != nil {
+		return err
+	}
 
-func spawnChild(listener *gracefulListener) {
-	file := listener.File()
-	args := flag.Args()
+	// read before requests come, so health endpoints don't look config up on every request
+	f.healthEndpoints()
 
-	os.Setenv("FLOKI_CHILD_PROC", "1")
+	main := &listenerSpec{name: DefaultListener, addr: addr, handler: handler, tlsConfig: tlsConfig}
+	specs := append([]*listenerSpec{main}, f.listeners...)
 
-	cmd := exec.Command(executablePath, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.ExtraFiles = []*os.File{file}
+	if err := f.runHooks(stageConfigure); err != nil {
+		return err
+	}
 
-	err := cmd.Start()
+	pid, err := lockPidFile(pidFile)
 	if err != nil {
-		log.Fatalf("gracefulRestart: Failed to launch binary '%s', error: %v", executablePath, err)
+		return err
 	}
-}
+	defer pid.remove()
 
-var gracefulChild bool
-
-func (f *Floki) listenHTTP(addr string, handler http.Handler, pidFile string) error {
-	go func() {
-		// wait for parent process to delete it's pid file so it won't delete ours
-		time.Sleep(time.Second * 1)
-
-		err := ioutil.WriteFile(pidFile, []byte(strconv.Itoa(syscall.Getpid())), 0660)
-		if err != nil {
-			log.Println("can't write pid to file:", pidFile, ". Error:", err)
-		}
-	}()
-
-	if true {
-		return gracehttp.Serve(
-			&http.Server{Addr: addr, Handler: handler},
-		)
+	if err := f.runHooks(stageStart); err != nil {
+		return err
 	}
 
-	// in Dev & Test environments we don't need to daemonize the process
-	/*
-		if Env == Dev || Env == Test {
-			return http.ListenAndServe(addr, handler)
-		} */
-
-	log := f.Logger()
+	if err := f.openListeners(specs); err != nil {
+		return err
+	}
 
-	executablePath, _ = osext.Executable()
+	// certificates are watched when serving only, not with -check-config
+	if f.certificates != nil && f.Config.Bool("tlsWatch", true) {
+		f.watchCertificates()
+	}
 
-	server := &http.Server{
-		Addr:           addr,
-		Handler:        handler,
-		ReadTimeout:    1 * time.Second,
-		WriteTimeout:   1 * time.Second,
-		MaxHeaderBytes: 1 << 16}
+	f.restartFunc = func() error {
+		return f.restart(specs, pid)
+	}
 
-	var l net.Listener
-	var err error
+	stopSignals := f.handleSignals(syscall.SIGHUP, syscall.SIGUSR2, syscall.SIGTERM, syscall.SIGINT, syscall.SIGUSR1)
+	defer stopSignals()
 
-	if os.Getenv("FLOKI_CHILD_PROC") == "1" {
-		gracefulChild = true
+	// servers are registered before serving, so Shutdown started at any moment stops all of them
+	errs := make(chan error, len(specs))
+	for _, spec := range specs {
+		server := f.listenerServer(spec)
+		go func(spec *listenerSpec) {
+			errs <- f.serveListener(server, spec)
+		}(spec)
 	}
 
-	if gracefulChild {
-		f := os.NewFile(uintptr(3), "")
-		l, err = net.FileListener(f)
-	} else {
-		l, err = net.Listen("tcp", server.Addr)
+	if err := pid.write(f.log); err != nil {
+		f.log.Error("can't write pid to file", "file", pidFile, "error", err)
 	}
 
-	if err != nil {
-		return err
+	if err = f.runHooks(stageReady); err != nil {
+		f.log.Error("server is not ready, shutting down", "error", err)
+		go f.shutdownWithTimeout()
+	} else if err := notifyParent(); err != nil {
+		// listeners are accepting connections, parent process can stop now
+		f.log.Error("can't notify parent process", "error", err)
 	}
 
-	if gracefulChild {
-		parent := syscall.Getppid()
-		log.Println("killing parent:", parent)
-		syscall.Kill(parent, syscall.SIGTERM)
-
-		go func() {
-			// wait for parent process to delete it's pid file so it won't delete ours
-			time.Sleep(time.Second * 1)
-
-			err := ioutil.WriteFile(pidFile, []byte(strconv.Itoa(syscall.Getpid())), 0660)
-			if err != nil {
-				log.Println("can't write pid to file:", pidFile, ". Error:", err)
-			}
-		}()
+	for range specs {
+		if serveErr := <-errs; serveErr != http.ErrServerClosed && err == nil {
+			// one listener failed, stop the others
+			err = serveErr
+			go f.shutdownWithTimeout()
+		}
 	}
 
-	theListener = newGracefulListener(l)
+	// wait for in-flight requests to complete
+	<-f.stopped
 
-	if !gracefulChild {
-		spawnChild(theListener)
-	}
+	return err
+}
 
+// handleSignals starts a goroutine which reacts to the given signals until returned function is called.
+// SIGTERM and SIGINT shut the server down, SIGHUP reloads config and TLS certificates,
+// SIGUSR2 restarts the server gracefully, SIGUSR1 reopens log file.
+func (f *Floki) handleSignals(signals ...os.Signal) (stop func()) {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGHUP, syscall.SIGTERM, syscall.SIGINT)
+	signal.Notify(c, signals...)
 	go func() {
 		for s := range c {
-			log.Println("got signal:", s)
+			f.log.Info("got signal", "signal", s)
 
 			switch s {
-			case syscall.SIGTERM:
-				os.Remove(pidFile)
-				theListener.Close()
-
-				// waiting for running tasks to complete
-				httpWg.Wait()
-
-				f.triggerAppEvent("Shutdown")
-
-				os.Exit(0)
+			case syscall.SIGTERM, syscall.SIGINT:
+				go f.shutdownWithTimeout()
 
 			case syscall.SIGHUP:
-				f.triggerAppEvent("Reload")
-
-				log.Println("got SIGHUP! restarting gracefully..")
-				spawnChild(theListener)
+				if f.certificates != nil {
+					f.reloadCertificates()
+				}
+
+				if err := f.ReloadConfig(); err != nil {
+					f.log.Error("config reload failed", "error", err)
+				}
+
+			case syscall.SIGUSR2:
+				f.log.Info("restarting gracefully", "signal", s)
+				if err := f.Restart(); err != nil {
+					f.log.Error("graceful restart failed", "error", err)
+				}
+
+			case syscall.SIGUSR1:
+				f.reopenLogFile()
 			}
 		}
 	}()
 
-	server.Serve(theListener)
-
-	// waiting for running tasks to complete
-	httpWg.Wait()
-
-	return nil
+	return func() {
+		signal.Stop(c)
+		close(c)
+	}
 }
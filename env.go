@@ -1,7 +1,10 @@
 package floki
 
 import (
+	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 // Envs
@@ -11,21 +14,121 @@ const (
 	Test string = "test"
 )
 
-// Env is the environment that Martini is executing in. The MARTINI_ENV is read on initialization to set this variable.
-var Env = Dev
-var Root string
+// Option configures Floki instance created by New or Default. Error returned by
+// an option is returned by New.
+type Option func(f *Floki) error
 
-func setENV(e string) {
-	if len(e) > 0 {
-		Env = e
+// WithEnv sets environment of the application. By default it's taken from
+// FLOKI_ENV environment variable, or Dev when it's not set.
+func WithEnv(env string) Option {
+	return func(f *Floki) error {
+		f.Env = env
+		return nil
 	}
 }
 
-func init() {
-	setENV(os.Getenv("FLOKI_ENV"))
-	var err error
-	Root, err = os.Getwd()
-	if err != nil {
-		panic(err)
+// WithRoot sets application root directory, relative paths of config files,
+// templates, log and pid files are resolved against it. Working directory is used by default.
+func WithRoot(dir string) Option {
+	return func(f *Floki) error {
+		f.Root = dir
+		return nil
 	}
 }
+
+// WithTimeZone sets time zone of the application, "timeZone" config key is ignored then.
+func WithTimeZone(loc *time.Location) Option {
+	return func(f *Floki) error {
+		f.TimeZone = loc
+		return nil
+	}
+}
+
+// WithConfigFile sets path of config files, see loadConfigFiles. It takes precedence
+// over -config command line flag.
+func WithConfigFile(pattern string) Option {
+	return func(f *Floki) error {
+		f.configFile = pattern
+		return nil
+	}
+}
+
+// WithConfig sets config of the application, config files and -config flag are not used then.
+// See ParseConfig for creating config from data:
+//     conf, err := floki.ParseConfig("yaml", data)
+//     f, err := floki.Default(floki.WithConfig(conf))
+func WithConfig(c ConfigMap) Option {
+	return func(f *Floki) error {
+		f.Config = c
+		f.configGiven = true
+		return nil
+	}
+}
+
+// WithLog sets application Log. Log level and format from config, as well as "logFile",
+// are ignored then, access log entries are written to l at Info level.
+func WithLog(l Log) Option {
+	return func(f *Floki) error {
+		f.SetLog(l)
+		f.logOutput = &logWriter{log: l, level: LevelInfo}
+		f.logGiven = true
+		return nil
+	}
+}
+
+// WithTemplateDir sets directory of templates compiled by Run, "./templates" by default.
+// It's the same as "views dir" parameter.
+func WithTemplateDir(dir string) Option {
+	return func(f *Floki) error {
+		f.SetParameter("views dir", dir)
+		return nil
+	}
+}
+
+// WithMiddleware adds handlers to the application middleware. Default doesn't add
+// its default middleware then, so the whole set is under control of the caller.
+// Without handlers it gives an application with no middleware at all.
+func WithMiddleware(handlers ...HandlerFunc) Option {
+	return func(f *Floki) error {
+		f.Use(handlers...)
+		f.middlewareGiven = true
+		return nil
+	}
+}
+
+// WithListener registers additional listener, see AddListener.
+func WithListener(name, addr string, handler http.Handler) Option {
+	return func(f *Floki) error {
+		f.AddListener(name, addr, handler)
+		return nil
+	}
+}
+
+// configureEnv sets environment and root directory which were not given as options
+func (f *Floki) configureEnv() error {
+	if f.Env == "" {
+		f.Env = os.Getenv("FLOKI_ENV")
+	}
+
+	if f.Env == "" {
+		f.Env = Dev
+	}
+
+	if f.Root == "" {
+		root, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		f.Root = root
+	}
+
+	return nil
+}
+
+// path resolves relative path against application root directory
+func (f *Floki) path(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(f.Root, p)
+}
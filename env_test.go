@@ -0,0 +1,231 @@
+package floki
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultWithOptions(t *testing.T) {
+	conf, err := ParseConfig("yaml", []byte("timeZone: Europe/Berlin\nlimits:\n  api: 100\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	var called []string
+
+	f, err := Default(
+		WithEnv(Prod),
+		WithRoot("/srv/app"),
+		WithConfig(conf),
+		WithLog(NewLog(&buf, LevelInfo, nil)),
+		WithMiddleware(func(c *Context) {
+			called = append(called, "middleware")
+			c.Next()
+		}),
+		WithListener("admin", "127.0.0.1:0", nil),
+		WithTemplateDir("views"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Env != Prod || f.path("floki.pid") != "/srv/app/floki.pid" {
+		t.Errorf("unexpected env %q or root %q", f.Env, f.Root)
+	}
+
+	if f.Config.Int("limits.api", 0) != 100 || f.TimeZone.String() != "Europe/Berlin" {
+		t.Errorf("config was not applied: %v, time zone %v", f.Config, f.TimeZone)
+	}
+
+	if len(f.listeners) != 1 || f.listeners[0].name != "admin" {
+		t.Errorf("unexpected listeners %v", f.listeners)
+	}
+
+	if f.GetParameter("views dir") != "views" {
+		t.Errorf("unexpected template dir %v", f.GetParameter("views dir"))
+	}
+
+	// default access log and recovery are replaced by given middleware
+	if len(f.Handlers) != 1 {
+		t.Errorf("expected only given middleware, got %d handlers", len(f.Handlers))
+	}
+
+	f.GET("/", func(c *Context) {
+		called = append(called, "handler")
+		c.Send(200, "ok")
+	})
+
+	f.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if strings.Join(called, ",") != "middleware,handler" {
+		t.Errorf("unexpected calls %v", called)
+	}
+
+	if !strings.Contains(buf.String(), "loaded config") {
+		t.Errorf("expected given log to be used, got %q", buf.String())
+	}
+}
+
+func TestDefaultMiddleware(t *testing.T) {
+	f, err := Default(WithEnv(Dev), WithConfig(ConfigMap{}), WithLog(NewLog(&bytes.Buffer{}, LevelInfo, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// access log and recovery
+	if len(f.Handlers) != 2 {
+		t.Errorf("expected default middleware, got %d handlers", len(f.Handlers))
+	}
+
+	f.GET("/panic", func(c *Context) {
+		panic("boom")
+	})
+
+	res := httptest.NewRecorder()
+	f.ServeHTTP(res, httptest.NewRequest("GET", "/panic", nil))
+
+	if res.Code != http.StatusInternalServerError {
+		t.Errorf("expected recovered panic, got %d", res.Code)
+	}
+}
+
+func TestDefaultReturnsErrors(t *testing.T) {
+	log := WithLog(NewLog(&bytes.Buffer{}, LevelInfo, nil))
+
+	_, err := Default(log, WithEnv(Test), WithConfigFile(filepath.Join(t.TempDir(), "%s.json")))
+	if err == nil || !strings.Contains(err.Error(), "error loading config") {
+		t.Errorf("expected config error, got %v", err)
+	}
+
+	optionErr := errors.New("option failed")
+	_, err = New(func(f *Floki) error { return optionErr })
+	if err != optionErr {
+		t.Errorf("expected option error, got %v", err)
+	}
+
+	if _, err = ParseConfig("ini", nil); err == nil {
+		t.Error("expected error of unknown format")
+	}
+}
+
+func TestWithTimeZone(t *testing.T) {
+	conf, err := ParseConfig("json", []byte(`{"timeZone": "Europe/Berlin"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Default(WithConfig(conf), WithTimeZone(time.UTC), WithLog(NewLog(&bytes.Buffer{}, LevelInfo, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.TimeZone != time.UTC {
+		t.Errorf("expected time zone option to win, got %v", f.TimeZone)
+	}
+}
+
+func TestWithArgs(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "test.json"), []byte(`{"logFormat": "json"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	log := WithLog(NewLog(&bytes.Buffer{}, LevelInfo, nil))
+	args := []string{"-config", filepath.Join(dir, "%s.json"), "-check-config"}
+
+	f, err := Default(log, WithEnv(Test), WithArgs(args))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Config.Str("logFormat", "") != "json" {
+		t.Errorf("config from -config argument should be loaded, got %v", f.Config)
+	}
+
+	// config is checked instead of serving
+	if err = f.Listen("127.0.0.1:0"); err != ErrHandled {
+		t.Errorf("expected ErrHandled, got %v", err)
+	}
+
+	if _, err = Default(log, WithArgs([]string{"genkey"})); err != ErrHandled {
+		t.Errorf("expected command to be handled, got %v", err)
+	}
+
+	if _, err = New(WithArgs([]string{"-unknown"})); err == nil {
+		t.Error("expected error of unknown flag")
+	}
+}
+
+func TestRootPaths(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "config"), 0755)
+	os.Mkdir(filepath.Join(root, "templates"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "config", "test.json"), []byte(`{"name": "app"}`), 0644)
+	ioutil.WriteFile(filepath.Join(root, "templates", "index.jade"), []byte("index"), 0644)
+
+	// relative paths don't depend on working directory of the process
+	f, err := Default(
+		WithEnv(Test),
+		WithRoot(root),
+		WithConfigFile("config/%s.json"),
+		WithLog(NewLog(&bytes.Buffer{}, LevelInfo, nil)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := f.Config.Str("name", ""); name != "app" {
+		t.Errorf("config should be loaded from root, got %q", name)
+	}
+
+	if err = f.prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if templates, _ := f.GetParameter("templates").(map[string]*template.Template); templates["index"] == nil {
+		t.Errorf("templates should be compiled from root, got %v", templates)
+	}
+
+	paths := map[string]string{
+		"floki.pid":    filepath.Join(root, "floki.pid"),
+		"logs/app.log": filepath.Join(root, "logs", "app.log"),
+		"/var/log/app": "/var/log/app",
+		"":             "",
+	}
+	for path, expected := range paths {
+		if resolved := f.path(path); resolved != expected {
+			t.Errorf("%q: expected %q, got %q", path, expected, resolved)
+		}
+	}
+}
+
+func TestConfigureEnvDefaults(t *testing.T) {
+	t.Setenv("FLOKI_ENV", Prod)
+
+	f, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, _ := os.Getwd()
+	if f.Env != Prod || f.Root != wd {
+		t.Errorf("expected env from FLOKI_ENV and working directory as root, got %q and %q", f.Env, f.Root)
+	}
+
+	// options win over environment
+	if f, err = New(WithEnv(Test), WithRoot("/srv/app")); err != nil {
+		t.Fatal(err)
+	}
+	if f.Env != Test || f.Root != "/srv/app" {
+		t.Errorf("options should be used, got %q and %q", f.Env, f.Root)
+	}
+}
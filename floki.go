@@ -1,19 +1,21 @@
 package floki
 
 import (
+	"crypto/tls"
+	"fmt"
 	"github.com/go-floki/router"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var appEventHandlers map[string][]AppEventHandler = make(map[string][]AppEventHandler)
-var TimeZone *time.Location
-
 type (
 	// Used internally to collect errors that occurred during an http request.
 	errorMsg struct {
@@ -26,18 +28,61 @@ type (
 
 	Model map[string]interface{}
 
-	AppEventHandler func(app *Floki)
-
 	// Floki represents the top level web application. inject.Injector methods can be invoked to map services on a global level.
 	Floki struct {
 		*RouterGroup
+		log         Log
 		logger      *log.Logger
+		logOutput   io.Writer
+		logFile     *RotatingFile
 		params      map[string]interface{}
 		contextPool sync.Pool
 		router      *router.Router
 		handlers404 []HandlerFunc
 
+		trustedProxies []*net.IPNet
+		proxyHeader    string
+		certificates   *certStore
+		tlsConfig      *tls.Config
+		listeners      []*listenerSpec
+
+		serverList []*http.Server
+		serversMu  sync.Mutex
+		stopped    chan struct{}
+		inFlight   atomic.Int64
+		draining   atomic.Bool
+
+		restartFunc func() error
+		restarting  atomic.Bool
+		restartArgs []string
+
+		healthChecks []healthCheck
+		healthMu     sync.RWMutex
+		health       healthEndpoints
+		healthOnce   sync.Once
+
+		hooks   map[lifecycleStage][]LifecycleHook
+		hooksMu sync.Mutex
+
+		watchStops []func()
+		watchMu    sync.Mutex
+
+		configSchema []configSection
+		configFile   string
+		templates    templateState
+
+		// set by options, so Default doesn't override them
+		configGiven     bool
+		logGiven        bool
+		middlewareGiven bool
+
+		// command line given with WithArgs
+		command         []string
+		checkConfigOnly bool
+
 		Config      ConfigMap
+		Env         string
+		Root        string
 		TimeZone    *time.Location
 		BuildNumber string
 	}
@@ -45,58 +90,116 @@ type (
 	// Used internally to configure router, a RouterGroup is associated with a prefix
 	// and an array of handlers (middlewares)
 	RouterGroup struct {
-		Handlers []HandlerFunc
-		prefix   string
-		parent   *RouterGroup
-		floki    *Floki
+		Handlers  []HandlerFunc
+		prefix    string
+		parent    *RouterGroup
+		floki     *Floki
+		listeners []string
 	}
 
 	HandlerFunc func(*Context)
 )
 
 // New creates a bare bones Floki instance. Use this method if you want to have full control over the middleware that is used.
-func New() *Floki {
+// Environment, root directory, time zone, config, log, middleware and listeners can be given as options:
+//     f, err := floki.New(floki.WithEnv(floki.Test), floki.WithRoot("./testdata"))
+func New(opts ...Option) (*Floki, error) {
 	f := &Floki{
-		logger: log.New(os.Stdout, "[floki] ", 0),
-		params: make(map[string]interface{}),
-		router: router.New(),
+		params:  make(map[string]interface{}),
+		router:  router.New(),
+		stopped: make(chan struct{}),
+		hooks:   make(map[lifecycleStage][]LifecycleHook),
 	}
 
-	f.RouterGroup = &RouterGroup{nil, "/", nil, f}
+	f.RouterGroup = &RouterGroup{nil, "/", nil, f, nil}
 	f.contextPool.New = func() interface{} {
 		return &Context{Floki: f, Writer: &responseWriter{}}
 	}
 
 	f.router.NotFound = f.handle404
 
+	for _, opt := range opts {
+		if err := opt(f); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := f.configureEnv(); err != nil {
+		return nil, err
+	}
+
+	f.configureLog(os.Stdout)
+	f.ConfigSection("", flokiConfig{})
+
+	return f, nil
+}
+
+// Must is a helper that wraps a call to New or Default and panics if the error is non-nil:
+//     f := floki.Must(floki.Default())
+func Must(f *Floki, err error) *Floki {
+	if err != nil {
+		panic(err)
+	}
 	return f
 }
 
 // ServeHTTP is the HTTP Entry point for a Floki instance. Useful if you want to control your own HTTP server.
 func (f *Floki) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if f.serveHealth(res, req) {
+		return
+	}
+
+	f.inFlight.Add(1)
+	defer f.inFlight.Add(-1)
+
 	f.router.ServeHTTP(res, req)
 }
 
 // Run the http server. Listening on os.GetEnv("PORT") or 3000 by default.
-func (f *Floki) Run() {
-	logger := f.logger
+// It returns after the server is shut down, or when it can't be started.
+func (f *Floki) Run() error {
+	if err := f.prepare(); err != nil {
+		return err
+	}
 
-	//if Env == Prod {
-	runtime.GOMAXPROCS(runtime.NumCPU())
+	addr := listenAddr("3000")
 
-	// in Prod environment we log to file by default
-	logFile := f.Config.Str("logFile", "floki.log")
+	f.log.Info("listening", "addr", addr, "env", f.Env)
 
-	out, err := os.OpenFile(logFile, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0660)
-	if err != nil {
-		logger.Println("can't open log file", logFile, "for writing:", err)
-	} else {
-		f.logger = log.New(out, "[floki] ", 0)
-		logger = f.logger
-		log.SetOutput(out)
+	return f.Listen(addr)
+}
+
+// RunTLS runs the https server with certificates from config. Listening on os.GetEnv("PORT") or 3443 by default.
+// See ListenTLS for config keys.
+func (f *Floki) RunTLS() error {
+	if err := f.prepare(); err != nil {
+		return err
+	}
+
+	addr := listenAddr("3443")
+
+	f.log.Info("listening with TLS", "addr", addr, "env", f.Env)
+
+	return f.ListenTLS(addr)
+}
+
+// prepare opens log file and compiles templates before the server starts
+func (f *Floki) prepare() error {
+	// before log file takes over standard output
+	if err := f.runConfigCheck(); err != nil {
+		return err
+	}
+
+	//if Env == Prod {
+	runtime.GOMAXPROCS(runtime.NumCPU())
 
-		os.Stdout = out
-		os.Stderr = out
+	// in Prod environment we log to file by default, unless Log was given as option
+	if !f.logGiven {
+		out := f.openLogFile()
+		if out != nil {
+			f.configureLog(out)
+			log.SetOutput(out)
+		}
 	}
 	//}
 
@@ -107,28 +210,32 @@ func (f *Floki) Run() {
 		tplDir = tplDirValue.(string)
 	}
 
-	f.SetParameter("templates", f.compileTemplates(tplDir, logger))
+	templates, err := f.compileTemplates(f.path(tplDir))
+	if err != nil {
+		return err
+	}
+
+	f.SetParameter("templates", templates)
+	return nil
+}
 
+// listenAddr builds address from HOST and PORT environment variables
+func listenAddr(defaultPort string) string {
 	port := os.Getenv("PORT")
 	if port == "" {
-		port = "3000"
+		port = defaultPort
 	}
 
 	host := os.Getenv("HOST")
-	_ = host
-
-	addr := host + ":" + port
-
-	logger.Printf("listening on %s (%s)\n", addr, Env)
 
-	f.Listen(addr)
+	return host + ":" + port
 }
 
-func (f *Floki) Listen(addr string) {
-	pidFile := f.Config.Str("pidFile", "floki.pid")
-	if err := f.listenHTTP(addr, f, pidFile); err != nil {
-		panic(err)
-	}
+// Listen serves http on addr until the server is shut down. It returns ErrHandled
+// when the application is started with -check-config and config is valid.
+func (f *Floki) Listen(addr string) error {
+	pidFile := f.path(f.Config.Str("pidFile", "floki.pid"))
+	return f.serve(addr, f, pidFile, nil)
 }
 
 func (f *Floki) GetParameter(key string) interface{} {
@@ -153,27 +260,49 @@ func (f *Floki) initContext(c *Context, params router.Params, handlers []Handler
 	c.Keys = nil
 	c.index = -1
 	c.beforeFuncs = nil
+	c.log = nil
+	c.requestID = ""
+	c.clientIP = ""
 	return c
 }
 
-// Classic creates a classic Floki with some basic default middleware - floki.Logger, floki.Recovery and floki.Static.
-// Classic also maps floki.Routes as a service.
-func Default() *Floki {
-	f := New()
+// Default creates Floki with config loaded from files, see loadConfigFiles, and some basic default
+// middleware - floki.Logger and floki.Recovery. Default middleware is not added when WithMiddleware
+// option is given. Errors of loading config are returned instead of exiting the process.
+// Command given with WithArgs is run instead, and ErrHandled is returned then.
+func Default(opts ...Option) (*Floki, error) {
+	f, err := New(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = f.runArgsCommand(); err != nil {
+		return nil, err
+	}
+
+	if err = f.loadConfig(); err != nil {
+		return nil, err
+	}
 
-	f.loadConfig()
+	// access log is always on in Dev and can be enabled in other environments with "accessLog" key
+	if !f.middlewareGiven && (f.Env == Dev || f.Config.Bool("accessLog", false)) {
+		format := f.Config.Str("accessLogFormat", CombinedLogFormat)
+		if err = ValidateAccessLogFormat(format); err != nil {
+			return nil, fmt.Errorf("accessLogFormat: %v", err)
+		}
 
-	if Env == Dev {
-		f.Use(Logger())
+		f.Use(LoggerWithConfig(LoggerConfig{Format: format}))
 	}
 
 	if f.Config.Bool("enableProfiling", false) {
 		RegisterProfiler(f)
 	}
 
-	f.Use(Recovery())
+	if !f.middlewareGiven {
+		f.Use(Recovery())
+	}
 
-	return f
+	return f, nil
 }
 
 // Handler can be any callable function. Floki attempts to inject services into the handler's argument list.
@@ -201,31 +330,101 @@ func (f *Floki) handle404(w http.ResponseWriter, req *http.Request) {
 
 }
 
-func RegisterAppEventHandler(event string, handler AppEventHandler) {
-	handlers, exists := appEventHandlers[event]
+// Logger returns standard library logger which writes to application Log at Info level.
+// It's kept for modules which haven't switched to Log yet.
+func (f *Floki) Logger() *log.Logger {
+	return f.logger
+}
+
+// Log returns application wide leveled logger.
+func (f *Floki) Log() Log {
+	return f.log
+}
+
+// SetLog replaces application Log. Logger() is updated to write to the new Log as well.
+func (f *Floki) SetLog(l Log) {
+	f.log = l
+	f.logger = StdLogger(l)
+}
 
-	if exists {
-		handlers = append(handlers, handler)
-	} else {
-		handlers = make([]AppEventHandler, 8)
-		handlers = append(handlers[0:0], handler)
+// openLogFile opens "logFile" with rotation settings from config:
+//     logMaxSizeMB      - rotate when file grows beyond that size
+//     logRotateInterval - rotate periodically, e.g. "24h"
+//     logMaxBackups     - number of rotated files to keep
+//     logMaxAge         - remove rotated files older than that, e.g. "720h"
+//     logCompress       - gzip rotated files
+// Standard output and error are redirected to the log file as well, see redirectStdio.
+func (f *Floki) openLogFile() *RotatingFile {
+	logFile := &RotatingFile{
+		Filename:   f.path(f.Config.Str("logFile", "floki.log")),
+		MaxSize:    int64(f.Config.Int("logMaxSizeMB", 0)) << 20,
+		Interval:   f.Config.Duration("logRotateInterval", 0),
+		MaxBackups: f.Config.Int("logMaxBackups", 0),
+		MaxAge:     f.Config.Duration("logMaxAge", 0),
+		Compress:   f.Config.Bool("logCompress", false),
+		OnOpen: func(file *os.File) {
+			// Log writes to this file, and the file is locked while it's being opened
+			if err := redirectStdio(file); err != nil {
+				fmt.Fprintln(file, "[floki] can't redirect standard output to log file:", err)
+			}
+		},
 	}
 
-	appEventHandlers[event] = handlers
+	if err := logFile.Open(); err != nil {
+		f.log.Error("can't open log file for writing", "file", logFile.Filename, "error", err)
+		return nil
+	}
 
+	f.logFile = logFile
+	return logFile
 }
 
-func (f *Floki) Logger() *log.Logger {
-	return f.logger
+// reopenLogFile is called on SIGUSR1 after external tools moved the log file
+func (f *Floki) reopenLogFile() {
+	if f.logFile == nil {
+		return
+	}
+
+	if err := f.logFile.Reopen(); err != nil {
+		f.log.Error("can't reopen log file", "file", f.logFile.Filename, "error", err)
+		return
+	}
+
+	f.log.Info("log file reopened", "file", f.logFile.Filename)
 }
 
-func (f *Floki) triggerAppEvent(event string) {
-	handlers, exists := appEventHandlers[event]
-	if exists {
-		for idx := range handlers {
-			handlers[idx](f)
+// configureLog creates application Log writing to out. Level and format are taken
+// from "logLevel" and "logFormat" ("text" or "json") config keys.
+func (f *Floki) configureLog(out io.Writer) {
+	if f.logGiven {
+		return
+	}
+
+	level := LevelInfo
+	if f.Env == Dev {
+		level = LevelDebug
+	}
+
+	levelName := f.Config.Str("logLevel", "")
+	if levelName != "" {
+		parsed, err := ParseLogLevel(levelName)
+		if err != nil {
+			fmt.Fprintln(out, "[floki] invalid logLevel in config:", levelName)
+		} else {
+			level = parsed
 		}
 	}
+
+	var enc LogEncoder
+	switch f.Config.Str("logFormat", "text") {
+	case "json":
+		enc = JSONEncoder{}
+	default:
+		enc = TextEncoder{Prefix: "[floki] "}
+	}
+
+	f.logOutput = out
+	f.SetLog(NewLog(out, level, enc))
 }
 
 // Adds not found handlers
@@ -0,0 +1,219 @@
+package floki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+type (
+	// HealthCheck reports an error when a dependency of the application is not usable.
+	// It should return once ctx is done.
+	HealthCheck func(ctx context.Context) error
+
+	healthCheck struct {
+		name      string
+		check     HealthCheck
+		readiness bool
+	}
+
+	healthCheckResult struct {
+		Status string `json:"status"`
+	}
+
+	healthResult struct {
+		Status string                       `json:"status"`
+		Checks map[string]healthCheckResult `json:"checks,omitempty"`
+	}
+
+	// healthEndpoints are read from config once, not on every request
+	healthEndpoints struct {
+		healthPath string
+		readyPath  string
+		listeners  []string
+	}
+)
+
+const (
+	healthOK       = "ok"
+	healthFail     = "fail"
+	healthDraining = "draining"
+)
+
+// AddHealthCheck registers check which is run on every request to health endpoints:
+//     /healthz - liveness, fails when any check added by AddHealthCheck fails
+//     /readyz  - readiness, fails when any check fails or the server is shutting down
+// Liveness failure makes orchestrators restart the process, so checks of external
+// dependencies like a database should be added by AddReadinessCheck instead.
+// Both respond with aggregated JSON and status 200 or 503. Response tells only whether
+// each check passed, errors are logged, so they don't leak to clients. Checks run concurrently
+// and each one is limited by "healthCheckTimeout" from config, 5s by default. Paths are
+// configured by "healthPath" and "readyPath", empty path disables the endpoint.
+// "healthListeners" limits endpoints to the named listeners, see AddListener:
+//     "listeners": {"internal": {"addr": "127.0.0.1:9000"}},
+//     "healthListeners": ["internal"]
+//
+// Health endpoints are answered before routing, so they don't pass through middleware
+// and don't show up in access logs. Their config is read when the server starts.
+func (f *Floki) AddHealthCheck(name string, check HealthCheck) {
+	f.addHealthCheck(healthCheck{name: name, check: check})
+}
+
+// AddReadinessCheck registers check which is run by readiness endpoint only, see AddHealthCheck.
+// When it fails the instance stops getting requests, but it isn't restarted.
+func (f *Floki) AddReadinessCheck(name string, check HealthCheck) {
+	f.addHealthCheck(healthCheck{name: name, check: check, readiness: true})
+}
+
+func (f *Floki) addHealthCheck(check healthCheck) {
+	f.healthMu.Lock()
+	f.healthChecks = append(f.healthChecks, check)
+	f.healthMu.Unlock()
+}
+
+// healthEndpoints returns config of health endpoints, it's read on the first call
+func (f *Floki) healthEndpoints() *healthEndpoints {
+	f.healthOnce.Do(func() {
+		f.health = healthEndpoints{
+			healthPath: f.Config.Str("healthPath", "/healthz"),
+			readyPath:  f.Config.Str("readyPath", "/readyz"),
+			listeners:  f.Config.Strings("healthListeners", nil),
+		}
+	})
+	return &f.health
+}
+
+// serveHealth answers requests to health endpoints and reports whether request was handled
+func (f *Floki) serveHealth(w http.ResponseWriter, req *http.Request) bool {
+	if req.Method != "GET" && req.Method != "HEAD" || req.URL.Path == "" {
+		return false
+	}
+
+	endpoints := f.healthEndpoints()
+
+	var readiness bool
+	switch req.URL.Path {
+	case endpoints.healthPath:
+	case endpoints.readyPath:
+		readiness = true
+	default:
+		return false
+	}
+
+	if !endpoints.servedOn(listenerName(req)) {
+		return false
+	}
+
+	result := f.checkHealth(req.Context(), readiness)
+	if readiness && f.draining.Load() {
+		result.Status = healthDraining
+	}
+
+	status := http.StatusOK
+	if result.Status != healthOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", MIMEJSON)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if req.Method != "HEAD" {
+		json.NewEncoder(w).Encode(result)
+	}
+
+	return true
+}
+
+// servedOn checks if health endpoints are reachable on the named listener
+func (e *healthEndpoints) servedOn(listener string) bool {
+	if len(e.listeners) == 0 {
+		return true
+	}
+
+	for _, name := range e.listeners {
+		if name == listener {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHealth runs registered checks concurrently and aggregates their results.
+// Readiness checks are skipped unless readiness is set.
+func (f *Floki) checkHealth(ctx context.Context, readiness bool) healthResult {
+	var checks []healthCheck
+
+	f.healthMu.RLock()
+	for _, check := range f.healthChecks {
+		if readiness || !check.readiness {
+			checks = append(checks, check)
+		}
+	}
+	f.healthMu.RUnlock()
+
+	result := healthResult{Status: healthOK}
+	if len(checks) == 0 {
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.Config.Duration("healthCheckTimeout", 5*time.Second))
+	defer cancel()
+
+	type checkError struct {
+		name string
+		err  error
+	}
+
+	errs := make(chan checkError, len(checks))
+	for _, check := range checks {
+		go func(check healthCheck) {
+			errs <- checkError{check.name, runHealthCheck(ctx, check.check)}
+		}(check)
+	}
+
+	result.Checks = make(map[string]healthCheckResult, len(checks))
+	for range checks {
+		var e checkError
+
+		select {
+		case e = <-errs:
+		case <-ctx.Done():
+		}
+
+		if e.name == "" {
+			// checks which ignore ctx are reported as timed out
+			for _, check := range checks {
+				if _, done := result.Checks[check.name]; !done {
+					result.Checks[check.name] = healthCheckResult{healthFail}
+					f.log.Warn("health check failed", "check", check.name, "error", "timeout")
+				}
+			}
+			result.Status = healthFail
+			break
+		}
+
+		if e.err != nil {
+			result.Status = healthFail
+			result.Checks[e.name] = healthCheckResult{healthFail}
+			f.log.Warn("health check failed", "check", e.name, "error", e.err)
+		} else {
+			result.Checks[e.name] = healthCheckResult{Status: healthOK}
+		}
+	}
+
+	return result
+}
+
+// runHealthCheck calls check and turns panic into an error
+func runHealthCheck(ctx context.Context, check HealthCheck) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("health check panicked")
+		}
+	}()
+
+	return check(ctx)
+}
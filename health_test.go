@@ -0,0 +1,149 @@
+package floki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func checkHealthEndpoint(t *testing.T, f *Floki, path string, expectedCode int) healthResult {
+	w := httptest.NewRecorder()
+	f.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+	if w.Code != expectedCode {
+		t.Fatalf("%s: expected status %d, got %d: %s", path, expectedCode, w.Code, w.Body)
+	}
+
+	var result healthResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+
+	var dbErr error
+	f.AddHealthCheck("db", func(ctx context.Context) error {
+		return dbErr
+	})
+
+	result := checkHealthEndpoint(t, f, "/healthz", http.StatusOK)
+	if result.Status != healthOK || result.Checks["db"].Status != healthOK {
+		t.Errorf("unexpected result %+v", result)
+	}
+	checkHealthEndpoint(t, f, "/readyz", http.StatusOK)
+
+	dbErr = errors.New("password authentication failed for user app")
+	w := httptest.NewRecorder()
+	f.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	if w.Code != http.StatusServiceUnavailable || strings.Contains(w.Body.String(), "password") {
+		t.Errorf("failed check should not reveal its error, got %d: %s", w.Code, w.Body)
+	}
+
+	result = checkHealthEndpoint(t, f, "/readyz", http.StatusServiceUnavailable)
+	if result.Status != healthFail || result.Checks["db"].Status != healthFail {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// draining instance is alive, but shouldn't get new requests
+	dbErr = nil
+	f.draining.Store(true)
+
+	checkHealthEndpoint(t, f, "/healthz", http.StatusOK)
+	result = checkHealthEndpoint(t, f, "/readyz", http.StatusServiceUnavailable)
+	if result.Status != healthDraining {
+		t.Errorf("expected draining status, got %+v", result)
+	}
+}
+
+func TestReadinessCheck(t *testing.T) {
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+
+	f.AddHealthCheck("process", func(ctx context.Context) error {
+		return nil
+	})
+	f.AddReadinessCheck("db", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	// database outage must not get healthy processes restarted
+	result := checkHealthEndpoint(t, f, "/healthz", http.StatusOK)
+	if _, ok := result.Checks["db"]; ok || result.Checks["process"].Status != healthOK {
+		t.Errorf("liveness should run liveness checks only, got %+v", result)
+	}
+
+	result = checkHealthEndpoint(t, f, "/readyz", http.StatusServiceUnavailable)
+	if result.Checks["db"].Status != healthFail || result.Checks["process"].Status != healthOK {
+		t.Errorf("readiness should run all checks, got %+v", result)
+	}
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+	json.Unmarshal([]byte(`{"healthCheckTimeout": "50ms"}`), &f.Config.data)
+
+	f.AddHealthCheck("fast", func(ctx context.Context) error {
+		return nil
+	})
+	f.AddHealthCheck("stuck", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	result := checkHealthEndpoint(t, f, "/healthz", http.StatusServiceUnavailable)
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("health check should time out, took %s", elapsed)
+	}
+
+	if result.Checks["fast"].Status != healthOK || result.Checks["stuck"].Status != healthFail {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestHealthEndpointsSkipMiddleware(t *testing.T) {
+	f := Must(New())
+	f.Use(func(c *Context) {
+		t.Error("middleware should not run for health endpoints")
+	})
+	f.GET("/", func(c *Context) {})
+
+	checkHealthEndpoint(t, f, "/healthz", http.StatusOK)
+}
+
+func TestHealthEndpointsOnListener(t *testing.T) {
+	f := Must(New())
+	json.Unmarshal([]byte(`{"healthListeners": ["internal"], "healthPath": "/health"}`), &f.Config.data)
+	f.GET("/health", func(c *Context) {
+		c.Send(200, "route")
+	})
+
+	// requests through other listeners reach routes
+	w := httptest.NewRecorder()
+	f.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Body.String() != "route" {
+		t.Errorf("health endpoint should not be served on default listener, got %s", w.Body)
+	}
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	req = req.WithContext(context.WithValue(req.Context(), listenerNameKey{}, "internal"))
+
+	w = httptest.NewRecorder()
+	f.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"ok"`) {
+		t.Errorf("health endpoint should be served on internal listener, got %d: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,130 @@
+package floki
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type (
+	// LifecycleHook is called at a stage of application lifecycle. ctx expires after
+	// "hookTimeout" from config, 30s by default.
+	LifecycleHook func(ctx context.Context, app *Floki) error
+
+	lifecycleStage int
+)
+
+const (
+	stageConfigure lifecycleStage = iota
+	stageStart
+	stageReady
+	stageReload
+	stageDrain
+	stageShutdown
+)
+
+var stageNames = []string{"configure", "start", "ready", "reload", "drain", "shutdown"}
+
+func (s lifecycleStage) String() string {
+	return stageNames[s]
+}
+
+// OnConfigure adds hook which is called when the server starts, before anything else,
+// so modules can read config. Startup is aborted if the hook fails.
+func (f *Floki) OnConfigure(hook LifecycleHook) {
+	f.addHook(stageConfigure, hook)
+}
+
+// OnStart adds hook which is called after configuration and before listeners are opened,
+// e.g. to connect to databases. Startup is aborted if the hook fails.
+func (f *Floki) OnStart(hook LifecycleHook) {
+	f.addHook(stageStart, hook)
+}
+
+// OnReady adds hook which is called once listeners accept connections. If the hook fails,
+// the server is shut down, and on graceful restart the previous process keeps serving.
+func (f *Floki) OnReady(hook LifecycleHook) {
+	f.addHook(stageReady, hook)
+}
+
+// OnReload adds hook which is called after config is reloaded on SIGHUP or when
+// config files change. All reload hooks are called even if some of them fail.
+func (f *Floki) OnReload(hook LifecycleHook) {
+	f.addHook(stageReload, hook)
+}
+
+// OnDrain adds hook which is called when shutdown begins, before "drainDelay" and before
+// listeners are closed, e.g. to deregister from service discovery. Readiness endpoint
+// reports draining by then. All drain hooks are called even if some of them fail.
+func (f *Floki) OnDrain(hook LifecycleHook) {
+	f.addHook(stageDrain, hook)
+}
+
+// OnShutdown adds hook which is called after in-flight requests are drained.
+// Shutdown hooks are called in reverse order, and all of them are called even if some fail.
+func (f *Floki) OnShutdown(hook LifecycleHook) {
+	f.addHook(stageShutdown, hook)
+}
+
+func (f *Floki) addHook(stage lifecycleStage, hook LifecycleHook) {
+	f.hooksMu.Lock()
+	f.hooks[stage] = append(f.hooks[stage], hook)
+	f.hooksMu.Unlock()
+}
+
+// runHooks calls hooks of the stage one by one. Configure, start and ready hooks
+// stop at the first error, the others are all called and their errors are joined.
+func (f *Floki) runHooks(stage lifecycleStage) error {
+	f.hooksMu.Lock()
+	hooks := append([]LifecycleHook(nil), f.hooks[stage]...)
+	f.hooksMu.Unlock()
+
+	if stage == stageShutdown {
+		for i, j := 0, len(hooks)-1; i < j; i, j = i+1, j-1 {
+			hooks[i], hooks[j] = hooks[j], hooks[i]
+		}
+	}
+
+	stopOnError := stage <= stageReady
+	timeout := f.Config.Duration("hookTimeout", 30*time.Second)
+
+	var errs []error
+	for i, hook := range hooks {
+		if err := f.runHook(hook, timeout); err != nil {
+			err = fmt.Errorf("%s hook #%d failed: %v", stage, i+1, err)
+			if stopOnError {
+				return err
+			}
+
+			f.log.Error("lifecycle hook failed", "stage", stage, "error", err)
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// runHook calls hook and waits for it no longer than timeout. Panic is returned as an error.
+func (f *Floki) runHook(hook LifecycleHook, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		done <- hook(ctx, f)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timeout after %s", timeout)
+	}
+}
@@ -0,0 +1,132 @@
+package floki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newHooksApp(opts ...Option) *Floki {
+	f := Must(New(append([]Option{WithEnv(Dev)}, opts...)...))
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+	return f
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	f := newHooksApp()
+	other := newHooksApp()
+
+	var (
+		calls []string
+		mu    sync.Mutex
+	)
+	hook := func(name string, err error) LifecycleHook {
+		return func(ctx context.Context, app *Floki) error {
+			if app != f {
+				t.Error("hook should get its own application")
+			}
+			mu.Lock()
+			calls = append(calls, name)
+			mu.Unlock()
+			return err
+		}
+	}
+
+	f.OnConfigure(hook("configure", nil))
+	f.OnStart(hook("start", nil))
+	f.OnReady(hook("ready", nil))
+
+	ready := make(chan struct{})
+	f.OnReady(func(ctx context.Context, app *Floki) error {
+		close(ready)
+		return nil
+	})
+	f.OnDrain(func(ctx context.Context, app *Floki) error {
+		if !app.draining.Load() {
+			t.Error("readiness should fail before drain hooks are called")
+		}
+		return nil
+	})
+	f.OnDrain(hook("drain", errors.New("deregister failed")))
+	f.OnShutdown(hook("shutdown 1", errors.New("close failed")))
+	f.OnShutdown(hook("shutdown 2", nil))
+
+	other.OnStart(func(ctx context.Context, app *Floki) error {
+		t.Error("hooks of other application should not be called")
+		return nil
+	})
+
+	addr := freeAddr(t)
+	done := make(chan error, 1)
+	go func() {
+		done <- f.serve(addr, f, "", nil)
+	}()
+
+	<-ready
+
+	err := f.Shutdown(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "close failed") {
+		t.Errorf("shutdown hook error should be returned, got %v", err)
+	}
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	expected := []string{"configure", "start", "ready", "drain", "shutdown 2", "shutdown 1"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected hooks %v, called %v", expected, calls)
+	}
+}
+
+func TestLifecycleHookAbortsStart(t *testing.T) {
+	f := newHooksApp()
+
+	f.OnStart(func(ctx context.Context, app *Floki) error {
+		return errors.New("database is down")
+	})
+	f.OnReady(func(ctx context.Context, app *Floki) error {
+		t.Error("ready hook should not be called when start fails")
+		return nil
+	})
+
+	err := f.serve(freeAddr(t), f, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "database is down") {
+		t.Errorf("start hook error should be returned, got %v", err)
+	}
+}
+
+func TestLifecycleHookTimeout(t *testing.T) {
+	f := newHooksApp()
+	json.Unmarshal([]byte(`{"hookTimeout": "50ms"}`), &f.Config.data)
+
+	f.OnReload(func(ctx context.Context, app *Floki) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	f.OnReload(func(ctx context.Context, app *Floki) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	f.OnReload(func(ctx context.Context, app *Floki) error {
+		panic("oops")
+	})
+
+	start := time.Now()
+	err := f.runHooks(stageReload)
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("hooks should time out, took %s", elapsed)
+	}
+
+	if err == nil || !strings.Contains(err.Error(), "reload hook #2 failed: timeout") ||
+		!strings.Contains(err.Error(), "reload hook #3 failed: panic: oops") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,381 @@
+package floki
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultListener is the name of listener started on the address passed to Listen or ListenTLS
+const DefaultListener = "default"
+
+const (
+	unixPrefix = "unix:"
+
+	// listenFdsStart is the first file descriptor passed by systemd and by Restart
+	listenFdsStart = 3
+
+	// inheritedListenersEnv lists names of listeners passed to the child process in fd order
+	inheritedListenersEnv = "FLOKI_LISTENERS"
+)
+
+type (
+	// fileListener is implemented by *net.TCPListener and *net.UnixListener
+	fileListener interface {
+		File() (*os.File, error)
+	}
+
+	// listenerSpec is one of the listeners the application accepts connections on
+	listenerSpec struct {
+		name      string
+		addr      string
+		handler   http.Handler
+		tlsConfig *tls.Config
+		h2c       bool
+		listener  net.Listener
+	}
+
+	namedListener struct {
+		name     string
+		listener net.Listener
+	}
+
+	listenerNameKey struct{}
+)
+
+// AddListener registers additional listener started by Run, Listen and their TLS variants next to
+// the main one. It can serve a sub-application, any http.Handler or, when handler is nil, this application.
+// Use RouterGroup.BindListeners to make routes reachable only on certain listeners:
+//     app.AddListener("admin", "127.0.0.1:9000", nil)
+//     admin := app.Group("/admin").BindListeners("admin")
+// Listeners are also read from "listeners" config section:
+//     "listeners": {"admin": {"addr": "127.0.0.1:9000"}, "mesh": {"addr": ":8081", "h2c": true}}
+// All listeners are passed to the new process on graceful restart and drained together on Shutdown.
+func (f *Floki) AddListener(name, addr string, handler http.Handler) {
+	f.addListener(name, addr, handler, nil)
+}
+
+// AddListenerTLS registers additional https listener using certificates from config, see ListenTLS.
+func (f *Floki) AddListenerTLS(name, addr string, handler http.Handler) error {
+	tlsConfig, err := f.configureTLS()
+	if err != nil {
+		return err
+	}
+
+	f.addListener(name, addr, handler, tlsConfig)
+	return nil
+}
+
+func (f *Floki) addListener(name, addr string, handler http.Handler, tlsConfig *tls.Config) {
+	if handler == nil {
+		handler = f
+	}
+
+	f.listeners = append(f.listeners, &listenerSpec{
+		name:      name,
+		addr:      addr,
+		handler:   handler,
+		tlsConfig: tlsConfig,
+	})
+}
+
+// configureListeners adds listeners from "listeners" config section
+func (f *Floki) configureListeners() error {
+	var err error
+
+	f.Config.Map("listeners").EachMap(func(name string, conf ConfigMap) {
+		if err != nil {
+			return
+		}
+
+		if conf.Bool("tls", false) {
+			err = f.AddListenerTLS(name, conf.Str("addr", ""), nil)
+		} else {
+			f.AddListener(name, conf.Str("addr", ""), nil)
+		}
+
+		if err == nil {
+			f.listeners[len(f.listeners)-1].h2c = conf.Bool("h2c", false)
+		}
+	})
+
+	return err
+}
+
+// ListenerName returns name of the listener which accepted the request, DefaultListener if unknown.
+func (c *Context) ListenerName() string {
+	return listenerName(c.Request)
+}
+
+func listenerName(req *http.Request) string {
+	if name, ok := req.Context().Value(listenerNameKey{}).(string); ok {
+		return name
+	}
+	return DefaultListener
+}
+
+// openListeners gets listener for every spec. Listeners are inherited from the parent process on
+// graceful restart, taken from systemd socket activation, or opened on spec address otherwise.
+func (f *Floki) openListeners(specs []*listenerSpec) error {
+	byName := make(map[string]*listenerSpec, len(specs))
+	for _, spec := range specs {
+		byName[spec.name] = spec
+	}
+
+	var inherited []namedListener
+	var err error
+
+	if os.Getenv(inheritedListenersEnv) != "" {
+		inherited, err = parentListeners()
+	} else {
+		inherited, err = activationListeners()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for idx, named := range inherited {
+		spec := byName[named.name]
+
+		// systemd sockets without FileDescriptorName are matched by position
+		if spec == nil && named.name == "unknown" && idx < len(specs) {
+			spec = specs[idx]
+		}
+
+		if spec == nil || spec.listener != nil {
+			f.log.Warn("closing unused inherited socket", "name", named.name, "addr", named.listener.Addr())
+			named.listener.Close()
+			continue
+		}
+
+		f.log.Info("using inherited socket", "listener", spec.name, "addr", named.listener.Addr())
+		spec.listener = named.listener
+	}
+
+	for _, spec := range specs {
+		if spec.listener != nil {
+			continue
+		}
+
+		if spec.listener, err = f.listen(spec.addr); err != nil {
+			closeListeners(specs)
+			return fmt.Errorf("listener %s: %v", spec.name, err)
+		}
+	}
+
+	return nil
+}
+
+func closeListeners(specs []*listenerSpec) {
+	for _, spec := range specs {
+		if spec.listener != nil {
+			spec.listener.Close()
+			spec.listener = nil
+		}
+	}
+}
+
+// listenerFiles duplicates listener descriptors to pass them to a child process
+// and returns value for inheritedListenersEnv
+func listenerFiles(specs []*listenerSpec) ([]*os.File, string, error) {
+	files := make([]*os.File, 0, len(specs))
+	names := make([]string, 0, len(specs))
+
+	for _, spec := range specs {
+		file, err := listenerFile(spec.listener)
+		if err != nil {
+			for _, file := range files {
+				file.Close()
+			}
+			return nil, "", fmt.Errorf("listener %s: %v", spec.name, err)
+		}
+
+		files = append(files, file)
+		names = append(names, spec.name)
+	}
+
+	return files, strings.Join(names, ","), nil
+}
+
+// listenerServer creates http server for spec and registers it to be stopped by Shutdown.
+// It returns nil when the application is already shutting down.
+func (f *Floki) listenerServer(spec *listenerSpec) *http.Server {
+	server := f.newServer(spec.addr, spec.handler)
+	server.TLSConfig = spec.tlsConfig
+
+	if spec.h2c {
+		enableH2C(server)
+	}
+
+	name := spec.name
+	server.ConnContext = func(ctx context.Context, conn net.Conn) context.Context {
+		return context.WithValue(ctx, listenerNameKey{}, name)
+	}
+
+	if !f.addServer(server) {
+		return nil
+	}
+	return server
+}
+
+// serveListener runs server created by listenerServer on spec listener until Shutdown
+func (f *Floki) serveListener(server *http.Server, spec *listenerSpec) error {
+	if server == nil {
+		spec.listener.Close()
+		return http.ErrServerClosed
+	}
+
+	if spec.tlsConfig != nil {
+		return server.ServeTLS(spec.listener, "", "")
+	}
+	return server.Serve(spec.listener)
+}
+
+// listen creates listener for addr. Addresses starting with "unix:" are unix domain sockets:
+//     unix:/var/run/app.sock
+// Stale socket file left by a crashed process is removed, socket file permissions
+// are set from "socketMode" config key, e.g. "0660".
+func (f *Floki) listen(addr string) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixPrefix) {
+		return net.Listen("tcp", addr)
+	}
+
+	path := strings.TrimPrefix(addr, unixPrefix)
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode := f.Config.Str("socketMode", ""); mode != "" {
+		perm, err := strconv.ParseUint(mode, 8, 32)
+		if err == nil {
+			err = os.Chmod(path, os.FileMode(perm))
+		}
+
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("can't set socket mode %s on %s: %v", mode, path, err)
+		}
+	}
+
+	return l, nil
+}
+
+// removeStaleSocket removes socket file nobody listens on
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("socket %s is in use by another process", path)
+	}
+
+	return os.Remove(path)
+}
+
+// inheritedListener turns file descriptor passed by the parent process into a listener
+func inheritedListener(fd int) (net.Listener, error) {
+	file := os.NewFile(uintptr(fd), "listener"+strconv.Itoa(fd))
+	defer file.Close()
+
+	return net.FileListener(file)
+}
+
+// parentListeners returns listeners passed by the parent process on graceful restart
+func parentListeners() ([]namedListener, error) {
+	names := strings.Split(os.Getenv(inheritedListenersEnv), ",")
+	os.Unsetenv(inheritedListenersEnv)
+
+	listeners := make([]namedListener, 0, len(names))
+	for idx, name := range names {
+		l, err := inheritedListener(listenFdsStart + idx)
+		if err != nil {
+			return nil, fmt.Errorf("can't use listener %s passed by parent process: %v", name, err)
+		}
+		listeners = append(listeners, namedListener{name, l})
+	}
+
+	return listeners, nil
+}
+
+// activationListeners returns sockets passed by systemd socket activation. Sockets are named
+// by FileDescriptorName from the socket unit, "unknown" is used when the name isn't set.
+// LISTEN_* variables are removed from environment, so they are not passed
+// to processes spawned later.
+func activationListeners() ([]namedListener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != syscall.Getpid() {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]namedListener, 0, count)
+	for idx := 0; idx < count; idx++ {
+		fd := listenFdsStart + idx
+		syscall.CloseOnExec(fd)
+
+		l, err := inheritedListener(fd)
+		if err != nil {
+			return nil, fmt.Errorf("can't use socket passed by systemd (fd %d): %v", fd, err)
+		}
+
+		name := "unknown"
+		if idx < len(names) && names[idx] != "" {
+			name = names[idx]
+		}
+
+		listeners = append(listeners, namedListener{name, l})
+	}
+
+	return listeners, nil
+}
+
+// listenerFile duplicates listener file descriptor to pass it to a child process.
+// Unix socket file must survive the parent closing its listener.
+func listenerFile(l net.Listener) (*os.File, error) {
+	if unixListener, ok := l.(*net.UnixListener); ok {
+		unixListener.SetUnlinkOnClose(false)
+	}
+
+	filer, ok := l.(fileListener)
+	if !ok {
+		return nil, errors.New("listener doesn't support passing to child process")
+	}
+
+	return filer.File()
+}
@@ -0,0 +1,249 @@
+package floki
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const activationHelperEnv = "FLOKI_ACTIVATION_HELPER"
+
+func newListenerApp(t *testing.T, conf string) *Floki {
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+	f.Config = testConfig(t, conf)
+	return f
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+
+	// socket file left by a crashed process
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	f := newListenerApp(t, `{"socketMode": "0600"}`)
+
+	l, err := f.listen(unixPrefix + path)
+	if err != nil {
+		t.Fatalf("stale socket should be removed, got %v", err)
+	}
+	defer l.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("socket mode should be 0600, got %o", perm)
+	}
+
+	if _, err = f.listen(unixPrefix + path); err == nil {
+		t.Error("socket in use by another listener should be refused")
+	}
+	if _, err = os.Stat(path); err != nil {
+		t.Errorf("socket in use should be kept, got %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "app.txt")
+	ioutil.WriteFile(file, []byte("data"), 0644)
+	if _, err = f.listen(unixPrefix + file); err == nil {
+		t.Error("file which is not a socket should be refused")
+	}
+
+	f.Config = testConfig(t, `{"socketMode": "rw"}`)
+	if _, err = f.listen(unixPrefix + filepath.Join(t.TempDir(), "mode.sock")); err == nil {
+		t.Error("invalid socket mode should be rejected")
+	}
+}
+
+// TestActivationHelperProcess gets sockets from TestActivationListeners like from systemd
+func TestActivationHelperProcess(t *testing.T) {
+	if os.Getenv(activationHelperEnv) == "" {
+		t.Skip("started by activation tests only")
+	}
+
+	// pid of the process is known only after it is started
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+
+	f := newListenerApp(t, `{}`)
+	specs := []*listenerSpec{
+		{name: "web", addr: "127.0.0.1:0"},
+		{name: "admin", addr: "127.0.0.1:0"},
+	}
+
+	if err := f.openListeners(specs); err != nil {
+		fmt.Println("error", err)
+		os.Exit(1)
+	}
+
+	for _, spec := range specs {
+		fmt.Println(spec.name, spec.listener.Addr())
+	}
+
+	if os.Getenv("LISTEN_PID") != "" || os.Getenv("LISTEN_FDS") != "" || os.Getenv("LISTEN_FDNAMES") != "" {
+		fmt.Println("LISTEN_* variables should be removed")
+	}
+
+	os.Exit(0)
+}
+
+func TestActivationListeners(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns processes")
+	}
+
+	var files []*os.File
+	var addrs []string
+
+	for i := 0; i < 2; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+
+		file, err := l.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		files = append(files, file)
+		addrs = append(addrs, l.Addr().String())
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestActivationHelperProcess$")
+	cmd.ExtraFiles = files
+	// the second socket has no name and is matched by position
+	cmd.Env = append(os.Environ(), activationHelperEnv+"=1", "LISTEN_FDS=2", "LISTEN_FDNAMES=web:")
+
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("helper process failed: %v\n%s", err, out)
+	}
+
+	expected := fmt.Sprintf("web %s\nadmin %s\n", addrs[0], addrs[1])
+	if string(out) != expected {
+		t.Errorf("expected listeners:\n%s\ngot:\n%s", expected, out)
+	}
+}
+
+func TestActivationListenersOfOtherProcess(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+
+	listeners, err := activationListeners()
+	if err != nil || listeners != nil {
+		t.Errorf("sockets of other process should be ignored, got %v, %v", listeners, err)
+	}
+
+	if os.Getenv("LISTEN_FDS") != "1" {
+		t.Error("environment of other process should be left intact")
+	}
+}
+
+func TestBindListeners(t *testing.T) {
+	f := newListenerApp(t, `{}`)
+
+	f.GET("/", func(c *Context) {
+		c.Send(200, "public "+c.ListenerName())
+	})
+
+	admin := f.Group("/admin").BindListeners("admin")
+	admin.GET("/stats", func(c *Context) {
+		c.Send(200, "stats")
+	})
+	// nested groups keep listeners of the parent
+	admin.Group("/users").GET("/list", func(c *Context) {
+		c.Send(200, "users")
+	})
+
+	// both listeners are served like serve does, so requests get listener name from the connection
+	urls := make(map[string]string)
+	for _, name := range []string{DefaultListener, "admin"} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		spec := &listenerSpec{name: name, addr: l.Addr().String(), handler: f, listener: l}
+		go f.serveListener(f.listenerServer(spec), spec)
+		urls[name] = "http://" + l.Addr().String()
+	}
+
+	defer f.Shutdown(context.Background())
+
+	tests := []struct {
+		listener string
+		path     string
+		code     int
+		body     string
+	}{
+		{DefaultListener, "/", 200, "public default"},
+		{DefaultListener, "/admin/stats", 404, ""},
+		{DefaultListener, "/admin/users/list", 404, ""},
+		{"admin", "/", 200, "public admin"},
+		{"admin", "/admin/stats", 200, "stats"},
+		{"admin", "/admin/users/list", 200, "users"},
+	}
+
+	for _, test := range tests {
+		res, err := http.Get(urls[test.listener] + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		if res.StatusCode != test.code || (test.body != "" && string(body) != test.body) {
+			t.Errorf("%s %s: expected %d %q, got %d %q", test.listener, test.path, test.code, test.body, res.StatusCode, body)
+		}
+	}
+}
+
+func TestConfigureListeners(t *testing.T) {
+	f := newListenerApp(t, `{"listeners": {
+		"admin": {"addr": "127.0.0.1:9000"},
+		"mesh": {"addr": ":8081", "h2c": true}
+	}}`)
+
+	if err := f.configureListeners(); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, spec := range f.listeners {
+		names = append(names, fmt.Sprintf("%s %s %v", spec.name, spec.addr, spec.h2c))
+
+		if spec.handler != f {
+			t.Errorf("%s should serve the application", spec.name)
+		}
+	}
+
+	// listeners section is a map, so order of listeners is not defined
+	sort.Strings(names)
+	if joined := strings.Join(names, ", "); joined != "admin 127.0.0.1:9000 false, mesh :8081 true" {
+		t.Errorf("unexpected listeners: %s", joined)
+	}
+
+	f = newListenerApp(t, `{"listeners": {"secure": {"addr": ":8443", "tls": true}}}`)
+
+	if err := f.configureListeners(); err == nil {
+		t.Error("tls listener without certificates should fail")
+	}
+}
@@ -0,0 +1,294 @@
+package floki
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log levels
+const (
+	LevelDebug LogLevel = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+type (
+	// LogLevel is the severity of a log entry. Entries below the level of a Log are dropped.
+	LogLevel int
+
+	// Log is a leveled logger. Each method accepts a message followed by alternating
+	// keys and values which are attached to the entry as fields:
+	//     log.Info("user logged in", "user", id, "provider", "google")
+	Log interface {
+		Debug(msg string, keyvals ...interface{})
+		Info(msg string, keyvals ...interface{})
+		Warn(msg string, keyvals ...interface{})
+		Error(msg string, keyvals ...interface{})
+
+		// With returns a child Log which adds keyvals to every entry it writes.
+		With(keyvals ...interface{}) Log
+	}
+
+	// LogEntry is a single record handed to a LogEncoder.
+	LogEntry struct {
+		Time    time.Time
+		Level   LogLevel
+		Message string
+		Fields  []interface{}
+	}
+
+	// LogEncoder writes log entries to an output in some format.
+	LogEncoder interface {
+		Encode(w io.Writer, e *LogEntry) error
+	}
+
+	// TextEncoder writes entries as a single human readable line:
+	//     [floki] INFO listening addr=:3000 env=development
+	TextEncoder struct {
+		Prefix string
+		// TimeFormat is used to prepend entry time. Time is omitted if it is empty.
+		TimeFormat string
+	}
+
+	// JSONEncoder writes every entry as a JSON object on its own line.
+	JSONEncoder struct{}
+
+	// logCore is shared by a Log and all its children
+	logCore struct {
+		mu    sync.Mutex
+		out   io.Writer
+		level LogLevel
+		enc   LogEncoder
+	}
+
+	leveledLog struct {
+		core   *logCore
+		fields []interface{}
+	}
+
+	// logWriter feeds lines written by a standard *log.Logger into a Log
+	logWriter struct {
+		log   Log
+		level LogLevel
+	}
+)
+
+func (l LogLevel) String() string {
+	if l < LevelDebug || int(l) >= len(levelNames) {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLogLevel converts level name ("debug", "info", "warn" or "error") to LogLevel.
+func ParseLogLevel(name string) (LogLevel, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "warning" {
+		name = "warn"
+	}
+
+	for idx, levelName := range levelNames {
+		if levelName == name {
+			return LogLevel(idx), nil
+		}
+	}
+
+	return LevelInfo, fmt.Errorf("unknown log level: %q", name)
+}
+
+// NewLog creates a Log which writes entries of the given level and above to out.
+// If enc is nil TextEncoder is used.
+func NewLog(out io.Writer, level LogLevel, enc LogEncoder) Log {
+	if enc == nil {
+		enc = TextEncoder{Prefix: "[floki] "}
+	}
+
+	return &leveledLog{core: &logCore{out: out, level: level, enc: enc}}
+}
+
+// StdLogger returns a standard library logger which writes every line to l at Info level.
+// Use it to pass a Log to code which expects *log.Logger.
+func StdLogger(l Log) *log.Logger {
+	return log.New(&logWriter{log: l, level: LevelInfo}, "", 0)
+}
+
+func (l *leveledLog) Debug(msg string, keyvals ...interface{}) {
+	l.write(LevelDebug, msg, keyvals)
+}
+
+func (l *leveledLog) Info(msg string, keyvals ...interface{}) {
+	l.write(LevelInfo, msg, keyvals)
+}
+
+func (l *leveledLog) Warn(msg string, keyvals ...interface{}) {
+	l.write(LevelWarn, msg, keyvals)
+}
+
+func (l *leveledLog) Error(msg string, keyvals ...interface{}) {
+	l.write(LevelError, msg, keyvals)
+}
+
+func (l *leveledLog) With(keyvals ...interface{}) Log {
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+
+	return &leveledLog{core: l.core, fields: fields}
+}
+
+func (l *leveledLog) write(level LogLevel, msg string, keyvals []interface{}) {
+	core := l.core
+	if level < core.level {
+		return
+	}
+
+	fields := l.fields
+	if len(keyvals) > 0 {
+		fields = make([]interface{}, 0, len(l.fields)+len(keyvals))
+		fields = append(fields, l.fields...)
+		fields = append(fields, keyvals...)
+	}
+
+	entry := LogEntry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+		Fields:  fields,
+	}
+
+	// encode outside of the lock, so slow values don't block other goroutines
+	var buf bytes.Buffer
+	if err := core.enc.Encode(&buf, &entry); err != nil {
+		fmt.Fprintf(&buf, "[floki] ERROR failed to encode log entry: %v\n", err)
+	}
+
+	core.mu.Lock()
+	core.out.Write(buf.Bytes())
+	core.mu.Unlock()
+}
+
+func (enc TextEncoder) Encode(w io.Writer, e *LogEntry) error {
+	var buf bytes.Buffer
+
+	buf.WriteString(enc.Prefix)
+	if enc.TimeFormat != "" {
+		buf.WriteString(e.Time.Format(enc.TimeFormat))
+		buf.WriteByte(' ')
+	}
+
+	buf.WriteString(strings.ToUpper(e.Level.String()))
+	buf.WriteByte(' ')
+	buf.WriteString(e.Message)
+
+	eachField(e.Fields, func(key string, value interface{}) {
+		buf.WriteByte(' ')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+
+		s := fmt.Sprint(value)
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			s = fmt.Sprintf("%q", s)
+		}
+		buf.WriteString(s)
+	})
+
+	buf.WriteByte('\n')
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (enc JSONEncoder) Encode(w io.Writer, e *LogEntry) error {
+	var buf bytes.Buffer
+
+	buf.WriteString(`{"time":"`)
+	buf.WriteString(e.Time.Format(time.RFC3339Nano))
+	buf.WriteString(`","level":"`)
+	buf.WriteString(e.Level.String())
+	buf.WriteString(`","msg":`)
+	writeJSONValue(&buf, e.Message)
+
+	eachField(e.Fields, func(key string, value interface{}) {
+		buf.WriteByte(',')
+		writeJSONValue(&buf, key)
+		buf.WriteByte(':')
+		writeJSONValue(&buf, value)
+	})
+
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeJSONValue(buf *bytes.Buffer, value interface{}) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(b)
+}
+
+// eachField walks key-value pairs. Non-string keys are formatted with fmt
+// and a missing value for the last key is reported as "!MISSING".
+// Values of keys like "password" or "token" are redacted.
+func eachField(fields []interface{}, iterator func(key string, value interface{})) {
+	for i := 0; i < len(fields); i += 2 {
+		key, ok := fields[i].(string)
+		if !ok {
+			key = fmt.Sprint(fields[i])
+		}
+
+		var value interface{} = "!MISSING"
+		if i+1 < len(fields) {
+			value = fields[i+1]
+		}
+
+		if isSensitiveKey(key) {
+			value = redacted
+		}
+
+		iterator(key, value)
+	}
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+
+	switch w.level {
+	case LevelDebug:
+		w.log.Debug(msg)
+	case LevelWarn:
+		w.log.Warn(msg)
+	case LevelError:
+		w.log.Error(msg)
+	default:
+		w.log.Info(msg)
+	}
+
+	return len(p), nil
+}
+
+// newRequestID generates random identifier for requests which came without X-Request-Id header
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,45 @@
+package floki
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestLogLevelFilter(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := NewLog(&buf, LevelWarn, nil)
+	l.Info("dropped")
+	l.Warn("kept", "key", "some value")
+
+	if buf.String() != "[floki] WARN kept key=\"some value\"\n" {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestLogJSONWith(t *testing.T) {
+	var buf bytes.Buffer
+
+	l := NewLog(&buf, LevelDebug, JSONEncoder{}).With("request_id", "abc")
+	l.Debug("hello", "status", 200)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry["level"] != "debug" || entry["msg"] != "hello" || entry["request_id"] != "abc" || entry["status"] != 200.0 {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	StdLogger(NewLog(&buf, LevelInfo, nil)).Println("legacy", "line")
+
+	if buf.String() != "[floki] INFO legacy line\n" {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
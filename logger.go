@@ -1,35 +1,265 @@
 package floki
 
 import (
-	//	"log"
-	//"fmt"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"text/template"
 	"time"
 )
 
-// Logger returns a middleware handler that logs the request as it goes in and the response as it goes out.
+// Access log formats supported by LoggerWithConfig. Other format values should be
+// text/template source executed against AccessLogEntry, e.g.
+//     "{{.Method}} {{.Path}} {{.Status}} {{.Latency}}"
+const (
+	CommonLogFormat   = "common"
+	CombinedLogFormat = "combined"
+	JSONLogFormat     = "json"
+)
+
+const accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+type (
+	// AccessLogEntry describes a completed request.
+	AccessLogEntry struct {
+		Time       time.Time
+		RemoteAddr string
+		Method     string
+		Path       string
+		Query      string
+		Proto      string
+		Status     int
+		Size       int
+		Referer    string
+		UserAgent  string
+		RequestID  string
+		Latency    time.Duration
+	}
+
+	// LoggerConfig configures access log middleware.
+	LoggerConfig struct {
+		// Format is CommonLogFormat, CombinedLogFormat, JSONLogFormat or a custom template.
+		// CombinedLogFormat is used if empty.
+		Format string
+
+		// Output receives one line per request. Application log output is used if nil.
+		Output io.Writer
+
+		// SkipPaths disables logging for matching request paths. A path ending
+		// with "*" matches by prefix: "/static/*".
+		SkipPaths []string
+
+		// SkipStatus disables logging of responses with these status codes.
+		SkipStatus []int
+
+		// Skip is called after the request is handled; returning true drops the entry.
+		Skip func(c *Context) bool
+	}
+
+	accessLogFormatter func(w *bytes.Buffer, e *AccessLogEntry)
+)
+
+// Logger returns a middleware handler that writes a line in combined log format for every request.
 func Logger() HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{})
+}
+
+// LoggerWithConfig returns access log middleware configured by conf.
+// It panics if format is invalid, see ValidateAccessLogFormat.
+func LoggerWithConfig(conf LoggerConfig) HandlerFunc {
+	format, err := accessLogFormat(conf.Format)
+	if err != nil {
+		panic(err)
+	}
+
+	skipStatus := make(map[int]bool, len(conf.SkipStatus))
+	for _, status := range conf.SkipStatus {
+		skipStatus[status] = true
+	}
+
 	return HandlerFunc(func(c *Context) {
-		res := c.Writer
 		req := c.Request
+		path := req.URL.Path
+
+		if matchPath(conf.SkipPaths, path) {
+			c.Next()
+			return
+		}
 
 		start := time.Now()
 
-		addr := req.Header.Get("X-Real-IP")
-		if addr == "" {
-			addr = req.Header.Get("X-Forwarded-For")
-			if addr == "" {
-				addr = req.RemoteAddr
+		c.Next()
+
+		res := c.Writer
+		status := res.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		if skipStatus[status] || (conf.Skip != nil && conf.Skip(c)) {
+			return
+		}
+
+		entry := AccessLogEntry{
+			Time:       start,
+			RemoteAddr: c.ClientIP(),
+			Method:     req.Method,
+			Path:       path,
+			Query:      req.URL.RawQuery,
+			Proto:      req.Proto,
+			Status:     status,
+			Size:       res.Size(),
+			Referer:    req.Referer(),
+			UserAgent:  req.UserAgent(),
+			RequestID:  c.RequestID(),
+			Latency:    time.Since(start),
+		}
+
+		var buf bytes.Buffer
+		format(&buf, &entry)
+
+		if buf.Len() == 0 || buf.Bytes()[buf.Len()-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+
+		out := conf.Output
+		if out == nil {
+			out = c.Floki.logOutput
+		}
+		out.Write(buf.Bytes())
+	})
+}
+
+// matchPath checks if path matches any of patterns. Patterns ending with "*" match by prefix.
+func matchPath(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(path, pattern[:len(pattern)-1]) {
+				return true
 			}
+		} else if pattern == path {
+			return true
 		}
+	}
+	return false
+}
 
-		log := c.Logger()
+// ValidateAccessLogFormat checks that format is one of the predefined formats
+// or a template which can be parsed.
+func ValidateAccessLogFormat(format string) error {
+	_, err := accessLogFormat(format)
+	return err
+}
 
-		log.Printf("Started %s %s for %s", req.Method, req.URL.Path, addr)
+func accessLogFormat(format string) (accessLogFormatter, error) {
+	switch format {
+	case "", CombinedLogFormat:
+		return formatCombined, nil
+	case CommonLogFormat:
+		return formatCommon, nil
+	case JSONLogFormat:
+		return formatJSON, nil
+	}
 
-		rw := res.(ResponseWriter)
-		c.Next()
+	// a misspelled format name would be logged as it is for every request
+	if !strings.Contains(format, "{{") {
+		return nil, fmt.Errorf("unknown access log format %q, use common, combined, json or a template", format)
+	}
+
+	tpl, err := template.New("accessLog").Parse(format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access log template: %v", err)
+	}
+
+	return func(w *bytes.Buffer, e *AccessLogEntry) {
+		if err := tpl.Execute(w, e); err != nil {
+			fmt.Fprintf(w, "access log template error: %v", err)
+		}
+	}, nil
+}
+
+func formatCommon(w *bytes.Buffer, e *AccessLogEntry) {
+	uri := e.Path
+	if e.Query != "" {
+		uri += "?" + e.Query
+	}
+
+	size := "-"
+	if e.Size > 0 {
+		size = fmt.Sprint(e.Size)
+	}
+
+	host := e.RemoteAddr
+	if host == "" {
+		host = "-"
+	}
+
+	fmt.Fprintf(w, "%s - - [%s] \"%s %s %s\" %d %s",
+		host, e.Time.Format(accessLogTimeFormat), escapeLogItem(e.Method), escapeLogItem(uri),
+		escapeLogItem(e.Proto), e.Status, size)
+}
+
+func formatCombined(w *bytes.Buffer, e *AccessLogEntry) {
+	formatCommon(w, e)
+
+	referer := e.Referer
+	if referer == "" {
+		referer = "-"
+	}
+
+	fmt.Fprintf(w, " \"%s\" \"%s\"", escapeLogItem(referer), escapeLogItem(e.UserAgent))
+}
+
+// escapeLogItem escapes request values like Apache does, so clients can't break a line of
+// the access log into several: quotes and backslashes are prefixed with backslash, control
+// characters and bytes outside of ASCII are written as \xNN.
+func escapeLogItem(value string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		switch ch := value[i]; {
+		case ch == '"' || ch == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case ch < 0x20 || ch >= 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	return b.String()
+}
 
-		log.Printf("Completed %v %s in %v\n", rw.Status(), http.StatusText(rw.Status()), time.Since(start))
+func formatJSON(w *bytes.Buffer, e *AccessLogEntry) {
+	json.NewEncoder(w).Encode(struct {
+		Time       string  `json:"time"`
+		RemoteAddr string  `json:"remote_addr"`
+		Method     string  `json:"method"`
+		Path       string  `json:"path"`
+		Query      string  `json:"query,omitempty"`
+		Proto      string  `json:"proto"`
+		Status     int     `json:"status"`
+		Size       int     `json:"bytes"`
+		Referer    string  `json:"referer,omitempty"`
+		UserAgent  string  `json:"user_agent,omitempty"`
+		RequestID  string  `json:"request_id"`
+		Latency    float64 `json:"latency_ms"`
+	}{
+		e.Time.Format(time.RFC3339Nano),
+		e.RemoteAddr,
+		e.Method,
+		e.Path,
+		e.Query,
+		e.Proto,
+		e.Status,
+		e.Size,
+		e.Referer,
+		e.UserAgent,
+		e.RequestID,
+		float64(e.Latency) / float64(time.Millisecond),
 	})
 }
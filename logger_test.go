@@ -0,0 +1,108 @@
+package floki
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLoggerCommonFormat(t *testing.T) {
+	var buf bytes.Buffer
+
+	f := Must(New())
+	f.Use(LoggerWithConfig(LoggerConfig{
+		Format:    CommonLogFormat,
+		Output:    &buf,
+		SkipPaths: []string{"/healthz"},
+	}))
+
+	f.GET("/hello", func(c *Context) {
+		c.Send(201, "hello")
+	})
+	f.GET("/healthz", func(c *Context) {
+		c.Send(200, "ok")
+	})
+
+	req, _ := http.NewRequest("GET", "/hello?a=1", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	f.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, _ = http.NewRequest("GET", "/healthz", nil)
+	f.ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := regexp.MustCompile(`^10\.0\.0\.1 - - \[[^\]]+\] "GET /hello\?a=1 HTTP/1\.1" 201 5\n$`)
+	if !expected.Match(buf.Bytes()) {
+		t.Errorf("unexpected access log: %q", buf.String())
+	}
+}
+
+func TestLoggerEscapesRequestValues(t *testing.T) {
+	var buf bytes.Buffer
+
+	f := Must(New())
+	f.Use(LoggerWithConfig(LoggerConfig{Format: CombinedLogFormat, Output: &buf}))
+	f.GET("/:name", func(c *Context) {
+		c.Send(200, "ok")
+	})
+
+	// decoded path tries to start a fake line in the log
+	req, _ := http.NewRequest("GET", `/%0a1.2.3.4%20-%20-%20%22GET%5cadmin%C3%A9`, nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("User-Agent", "agent\" 200 0\r")
+	f.ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := `"GET /\x0a1.2.3.4 - - \"GET\\admin\xc3\xa9 HTTP/1.1" 200 2 "-" "agent\" 200 0\x0d"` + "\n"
+	if line := buf.String(); strings.Count(line, "\n") != 1 || !strings.HasSuffix(line, expected) {
+		t.Errorf("request values should be escaped, got %q", line)
+	}
+}
+
+func TestLoggerTemplateFormat(t *testing.T) {
+	var buf bytes.Buffer
+
+	f := Must(New())
+	f.Use(LoggerWithConfig(LoggerConfig{
+		Format:     "{{.Method}} {{.Path}} {{.Status}} {{.UserAgent}}",
+		Output:     &buf,
+		SkipStatus: []int{404},
+	}))
+
+	f.GET("/", func(c *Context) {
+		c.Send(200, "index")
+	})
+	f.GET("/missing", func(c *Context) {
+		c.Send(404, "not found")
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	f.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, _ = http.NewRequest("GET", "/missing", nil)
+	f.ServeHTTP(httptest.NewRecorder(), req)
+
+	if buf.String() != "GET / 200 test-agent\n" {
+		t.Errorf("unexpected access log: %q", buf.String())
+	}
+}
+
+func TestAccessLogFormatErrors(t *testing.T) {
+	for _, format := range []string{"combine", "{{.Method"} {
+		if err := ValidateAccessLogFormat(format); err == nil {
+			t.Errorf("format %q should be rejected", format)
+		}
+	}
+
+	conf, err := ParseConfig("json", []byte(`{"accessLogFormat": "combine"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Default(WithEnv(Dev), WithConfig(conf), WithLog(NewLog(&bytes.Buffer{}, LevelInfo, nil)))
+	if err == nil || !strings.Contains(err.Error(), "accessLogFormat") {
+		t.Errorf("expected error of invalid format, got %v", err)
+	}
+}
@@ -15,7 +15,7 @@ const (
 )
 
 func newServer(useGzip bool) *floki.Floki {
-	r := floki.New()
+	r := floki.Must(floki.New())
 
 	if useGzip {
 		r.Use(GzipMiddleware(DefaultCompression))
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-floki/floki"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+var errNoRules = errors.New("no allow or deny rules")
+
+type (
+	// IPFilter allows or rejects requests by client IP address. Deny rules take precedence,
+	// and when allow list is not empty only addresses matching it get through.
+	// Client address is resolved with Context.ClientIP, so configure trusted proxies
+	// if the application runs behind a reverse proxy.
+	IPFilter struct {
+		// Forbidden handles rejected requests. It responds with 403 by default.
+		Forbidden floki.HandlerFunc
+
+		rules atomic.Value
+	}
+
+	ipRules struct {
+		allow []*net.IPNet
+		deny  []*net.IPNet
+	}
+
+	// ipFilterConfig is the schema of IP filter config section
+	ipFilterConfig struct {
+		Allow []string
+		Deny  []string
+	}
+)
+
+// NewIPFilter creates a filter from lists of CIDRs or single addresses.
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	filter := &IPFilter{}
+	if err := filter.Update(allow, deny); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// IPFilterFromConfig creates a filter from "allow" and "deny" lists in the given config section:
+//     "adminAccess": {
+//         "allow": ["10.0.0.0/8", "192.168.1.0/24"],
+//         "deny": ["10.0.0.13"]
+//     }
+// The filter is updated when the application is reloaded, and the section is
+// checked with -check-config. Missing section or section without rules is an error,
+// so a typo in the key doesn't leave the routes open.
+func IPFilterFromConfig(app *floki.Floki, key string) (*IPFilter, error) {
+	app.ConfigSection(key, ipFilterConfig{})
+
+	filter := &IPFilter{}
+	if err := filter.LoadConfig(app.Config.Map(key)); err != nil {
+		return nil, fmt.Errorf("IP filter %q: %v", key, err)
+	}
+
+	app.OnReload(func(ctx context.Context, app *floki.Floki) error {
+		if err := filter.LoadConfig(app.Config.Map(key)); err != nil {
+			return fmt.Errorf("can't reload IP filter %q, keeping previous rules: %v", key, err)
+		}
+		return nil
+	})
+
+	return filter, nil
+}
+
+func (c *ipFilterConfig) Validate() error {
+	if len(c.Allow) == 0 && len(c.Deny) == 0 {
+		return errNoRules
+	}
+	if _, err := floki.ParseNetworks(c.Allow); err != nil {
+		return fmt.Errorf("allow: %v", err)
+	}
+	if _, err := floki.ParseNetworks(c.Deny); err != nil {
+		return fmt.Errorf("deny: %v", err)
+	}
+	return nil
+}
+
+// LoadConfig replaces rules with "allow" and "deny" lists from conf. Rules are left unchanged
+// if both lists are empty.
+func (f *IPFilter) LoadConfig(conf floki.ConfigMap) error {
+	allow, deny := conf.Strings("allow", nil), conf.Strings("deny", nil)
+	if len(allow) == 0 && len(deny) == 0 {
+		return errNoRules
+	}
+
+	return f.Update(allow, deny)
+}
+
+// Update atomically replaces filter rules. Rules are left unchanged if any of the networks is invalid.
+func (f *IPFilter) Update(allow, deny []string) error {
+	rules := &ipRules{}
+
+	var err error
+	if rules.allow, err = floki.ParseNetworks(allow); err != nil {
+		return err
+	}
+	if rules.deny, err = floki.ParseNetworks(deny); err != nil {
+		return err
+	}
+
+	f.rules.Store(rules)
+	return nil
+}
+
+// Allowed checks the address against current rules. Filter without rules allows everyone.
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	rules, _ := f.rules.Load().(*ipRules)
+	if rules == nil {
+		rules = &ipRules{}
+	}
+
+	if ip == nil || containsIP(rules.deny, ip) {
+		return false
+	}
+
+	return len(rules.allow) == 0 || containsIP(rules.allow, ip)
+}
+
+// Middleware returns handler which stops requests from addresses which are not allowed.
+func (f *IPFilter) Middleware() floki.HandlerFunc {
+	return func(c *floki.Context) {
+		if f.Allowed(net.ParseIP(c.ClientIP())) {
+			c.Next()
+			return
+		}
+
+		c.Log().Warn("request rejected by IP filter", "ip", c.ClientIP())
+
+		if f.Forbidden != nil {
+			f.Forbidden(c)
+		} else {
+			c.Send(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+		}
+
+		c.Abort(-1)
+	}
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
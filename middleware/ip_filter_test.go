@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"github.com/go-floki/floki"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestIPFilter(t *testing.T) {
+	filter, err := NewIPFilter([]string{"10.0.0.0/8", "192.168.1.1"}, []string{"10.0.0.13"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := floki.Must(floki.New())
+	r.Use(filter.Middleware())
+	r.GET("/admin", func(c *floki.Context) {
+		c.Send(200, "admin")
+	})
+
+	tests := map[string]int{
+		"10.1.2.3:100":    http.StatusOK,
+		"192.168.1.1:100": http.StatusOK,
+		"192.168.1.2:100": http.StatusForbidden,
+		"10.0.0.13:100":   http.StatusForbidden,
+	}
+
+	for addr, code := range tests {
+		req, _ := http.NewRequest("GET", "/admin", nil)
+		req.RemoteAddr = addr
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != code {
+			t.Errorf("%s: status code should be %d, was %d", addr, code, w.Code)
+		}
+	}
+
+	if err := filter.Update([]string{"not a network"}, nil); err == nil {
+		t.Error("invalid network should be rejected")
+	}
+}
+
+func TestIPFilterWithoutRules(t *testing.T) {
+	filter := &IPFilter{}
+
+	if !filter.Allowed(net.ParseIP("10.0.0.1")) {
+		t.Error("filter without rules should allow everyone")
+	}
+	if filter.Allowed(nil) {
+		t.Error("unknown address should not be allowed")
+	}
+}
+
+func TestIPFilterFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "test.json")
+
+	writeConfig := func(allow string) {
+		conf := `{"trustedProxies": ["127.0.0.1"], "admin": {"allow": [` + allow + `]}}`
+		if err := ioutil.WriteFile(configFile, []byte(conf), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(`"10.0.0.0/8"`)
+
+	app, err := floki.Default(
+		floki.WithEnv(floki.Test),
+		floki.WithConfigFile(filepath.Join(dir, "%s.json")),
+		floki.WithLog(floki.NewLog(ioutil.Discard, floki.LevelError, nil)),
+		floki.WithMiddleware(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter, err := IPFilterFromConfig(app, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filter.Forbidden = func(c *floki.Context) {
+		c.Send(http.StatusUnauthorized, "go away")
+	}
+
+	app.Use(filter.Middleware())
+	app.GET("/admin", func(c *floki.Context) {
+		c.Send(200, "admin")
+	})
+
+	// client address is taken from X-Forwarded-For of trusted proxy
+	request := func(forwardedFor string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/admin", nil)
+		req.RemoteAddr = "127.0.0.1:100"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request("10.1.2.3"); w.Code != http.StatusOK {
+		t.Errorf("allowed client should get through, got %d", w.Code)
+	}
+
+	if w := request("192.168.1.1"); w.Code != http.StatusUnauthorized || w.Body.String() != "go away" {
+		t.Errorf("custom forbidden handler should respond, got %d: %s", w.Code, w.Body)
+	}
+
+	writeConfig(`"192.168.1.0/24"`)
+	if err = app.ReloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := request("192.168.1.1"); w.Code != http.StatusOK {
+		t.Errorf("reloaded rules should allow client, got %d", w.Code)
+	}
+	if w := request("10.1.2.3"); w.Code != http.StatusUnauthorized {
+		t.Errorf("reloaded rules should reject client, got %d", w.Code)
+	}
+
+	// invalid rules are rejected by the section schema and current ones are kept
+	writeConfig(`"not a network"`)
+	if err = app.ReloadConfig(); err == nil {
+		t.Error("invalid rules should be rejected")
+	}
+
+	if w := request("192.168.1.1"); w.Code != http.StatusOK {
+		t.Errorf("current rules should be kept, got %d", w.Code)
+	}
+
+	writeConfig(``)
+	if err = app.ReloadConfig(); err == nil {
+		t.Error("section without rules should be rejected on reload")
+	}
+
+	if w := request("10.1.2.3"); w.Code != http.StatusUnauthorized {
+		t.Errorf("current rules should be kept, got %d", w.Code)
+	}
+}
+
+func TestIPFilterFromConfigWithoutRules(t *testing.T) {
+	configs := map[string]string{
+		"missing section": `{"adminAccess": {"allow": ["10.0.0.0/8"]}}`,
+		"empty section":   `{"admin": {}}`,
+		"empty lists":     `{"admin": {"allow": [], "deny": []}}`,
+	}
+
+	for name, conf := range configs {
+		dir := t.TempDir()
+		if err := ioutil.WriteFile(filepath.Join(dir, "test.json"), []byte(conf), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		app, err := floki.Default(
+			floki.WithEnv(floki.Test),
+			floki.WithConfigFile(filepath.Join(dir, "%s.json")),
+			floki.WithLog(floki.NewLog(ioutil.Discard, floki.LevelError, nil)),
+			floki.WithMiddleware(),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = IPFilterFromConfig(app, "admin"); err == nil {
+			t.Errorf("%s: filter without rules should not be created", name)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package floki
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// pidFileFdEnv is the descriptor of locked pid file passed to new process by Restart
+const pidFileFdEnv = "FLOKI_PID_FD"
+
+// pidFile is a pid file locked with advisory lock for as long as the server is running.
+// The lock is shared with new process on graceful restart, so there is no moment
+// when another instance could take the file over.
+type pidFile struct {
+	path string
+	file *os.File
+}
+
+// lockPidFile creates and locks pid file at path. It fails when another running instance holds the lock.
+// Pid file left by a process which is gone is reclaimed. Empty path disables pid file.
+func lockPidFile(path string) (*pidFile, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	if file, err := inheritedPidFile(); file != nil || err != nil {
+		return &pidFile{path, file}, err
+	}
+
+	for {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0660)
+		if err != nil {
+			return nil, fmt.Errorf("can't open pid file %s: %v", path, err)
+		}
+
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == syscall.EWOULDBLOCK {
+			pid := readPid(file)
+			file.Close()
+			return nil, fmt.Errorf("pid file %s is locked, another instance is running with pid %d", path, pid)
+		}
+
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("can't lock pid file %s: %v", path, err)
+		}
+
+		// the file may have been removed by its previous owner while we were waiting for the lock
+		if !sameFile(file, path) {
+			file.Close()
+			continue
+		}
+
+		return &pidFile{path, file}, nil
+	}
+}
+
+// inheritedPidFile returns pid file locked by the parent process if this process was started by Restart
+func inheritedPidFile() (*os.File, error) {
+	value := os.Getenv(pidFileFdEnv)
+	if value == "" {
+		return nil, nil
+	}
+
+	os.Unsetenv(pidFileFdEnv)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %q", pidFileFdEnv, value)
+	}
+
+	return os.NewFile(uintptr(fd), "pidfile"), nil
+}
+
+// write replaces content of pid file with pid of this process.
+// A pid left by a process which is gone is logged as reclaimed.
+func (p *pidFile) write(log Log) error {
+	if p == nil {
+		return nil
+	}
+
+	if pid := readPid(p.file); pid != 0 && pid != os.Getpid() && !processExists(pid) {
+		log.Warn("reclaiming stale pid file", "file", p.path, "pid", pid)
+	}
+
+	if err := p.file.Truncate(0); err != nil {
+		return err
+	}
+
+	_, err := p.file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	return err
+}
+
+// remove deletes pid file unless it was already taken over by a new process, and releases the lock
+func (p *pidFile) remove() {
+	if p == nil {
+		return
+	}
+
+	if readPid(p.file) == os.Getpid() {
+		os.Remove(p.path)
+	}
+
+	p.file.Close()
+}
+
+func readPid(file *os.File) int {
+	data, err := ioutil.ReadAll(io.NewSectionReader(file, 0, 32))
+	if err != nil {
+		return 0
+	}
+
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// processExists checks whether process with pid is running
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// sameFile checks whether path still refers to the opened file
+func sameFile(file *os.File, path string) bool {
+	opened, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(opened, current)
+}
@@ -0,0 +1,62 @@
+package floki
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPidFileLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "floki.pid")
+
+	pid, err := lockPidFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = pid.write(NewLog(ioutil.Discard, LevelError, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = lockPidFile(path)
+	if err == nil {
+		t.Fatal("second instance should not get locked pid file")
+	}
+
+	if !strings.Contains(err.Error(), strconv.Itoa(os.Getpid())) {
+		t.Errorf("error should mention pid of running instance: %v", err)
+	}
+
+	pid.remove()
+
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Error("pid file should be removed")
+	}
+}
+
+func TestPidFileStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "floki.pid")
+
+	// pid of a process which is gone, pids are limited by 2^22 on linux
+	if err := ioutil.WriteFile(path, []byte("99999999\n"), 0660); err != nil {
+		t.Fatal(err)
+	}
+
+	pid, err := lockPidFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pid.remove()
+
+	if err = pid.write(NewLog(ioutil.Discard, LevelError, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	if strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("stale pid should be replaced, got %q", data)
+	}
+}
@@ -6,10 +6,10 @@ import (
 
 func RegisterProfiler(m *Floki) {
 
-	m.logger.Println("profiling enabled. url: /debug/pprof/")
+	m.log.Info("profiling enabled", "url", "/debug/pprof/")
 
 	m.GET("/debug/pprof/:id", func(c *Context) {
-		c.Logger().Println("id: ", c.Param("id"))
+		c.Log().Debug("pprof", "id", c.Param("id"))
 		//		pprof.Index(c.Writer, c.Request)
 		switch c.Param("id") {
 		case "cmdline":
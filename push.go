@@ -0,0 +1,75 @@
+package floki
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	// scripts, images and stylesheets or preloaded resources
+	assetSrcRe  = regexp.MustCompile(`(?i)<(?:script|img)\s[^>]*?\bsrc\s*=\s*["']([^"']+)["']`)
+	assetLinkRe = regexp.MustCompile(`(?i)<link\s[^>]*>`)
+	linkRelRe   = regexp.MustCompile(`(?i)\brel\s*=\s*["']?(stylesheet|preload|modulepreload)\b`)
+	linkHrefRe  = regexp.MustCompile(`(?i)\bhref\s*=\s*["']([^"']+)["']`)
+)
+
+// PushAssets initiates HTTP/2 server push of the given paths. It does nothing
+// if the client connection doesn't support push.
+func (c *Context) PushAssets(paths ...string) {
+	for _, path := range paths {
+		err := c.Writer.Push(path, nil)
+		if err == http.ErrNotSupported {
+			return
+		}
+
+		if err != nil {
+			c.Log().Debug("can't push asset", "asset", path, "error", err)
+		}
+	}
+}
+
+// RenderWithPush renders template like Render and pushes local scripts, images and stylesheets
+// referenced by the result before sending it, so the browser doesn't have to request them.
+func (c *Context) RenderWithPush(tplName string, data Model) {
+	buf := c.renderTemplate(tplName, data)
+	if buf == nil {
+		return
+	}
+
+	c.PushAssets(findAssets(buf.Bytes())...)
+
+	c.Writer.WriteHeader(200)
+	c.Writer.Write(buf.Bytes())
+}
+
+// findAssets returns local paths of resources referenced by html
+func findAssets(html []byte) []string {
+	var assets []string
+	seen := make(map[string]bool)
+
+	add := func(path string) {
+		// only paths on the same host can be pushed
+		if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || seen[path] {
+			return
+		}
+		seen[path] = true
+		assets = append(assets, path)
+	}
+
+	for _, match := range assetSrcRe.FindAllSubmatch(html, -1) {
+		add(string(match[1]))
+	}
+
+	for _, link := range assetLinkRe.FindAll(html, -1) {
+		if !linkRelRe.Match(link) {
+			continue
+		}
+
+		if href := linkHrefRe.FindSubmatch(link); href != nil {
+			add(string(href[1]))
+		}
+	}
+
+	return assets
+}
@@ -0,0 +1,132 @@
+package floki
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pushRecorder records pushed paths like HTTP/2 connection supporting push
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (r *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	r.pushed = append(r.pushed, target)
+	return nil
+}
+
+func TestFindAssets(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected []string
+	}{
+		{"script", `<script src="/js/app.js"></script>`, []string{"/js/app.js"}},
+		{"image", `<IMG class="logo" SRC='/img/logo.png'>`, []string{"/img/logo.png"}},
+		{"stylesheet", `<link rel="stylesheet" href="/css/app.css">`, []string{"/css/app.css"}},
+		{"preload", `<link href="/fonts/a.woff2" rel=preload as="font">`, []string{"/fonts/a.woff2"}},
+		{"modulepreload", `<link rel="modulepreload" href="/js/mod.js">`, []string{"/js/mod.js"}},
+		{"other link", `<link rel="icon" href="/favicon.ico">`, nil},
+		{"other host", `<script src="https://cdn.example.org/a.js"></script><img src="//cdn.example.org/a.png">`, nil},
+		{"relative", `<img src="img/a.png">`, nil},
+		{"duplicates", `<img src="/a.png"><img src="/a.png"><script src="/a.js"></script>`, []string{"/a.png", "/a.js"}},
+		{"none", `<p>text</p>`, nil},
+	}
+
+	for _, test := range tests {
+		if assets := findAssets([]byte(test.html)); !reflect.DeepEqual(assets, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, assets)
+		}
+	}
+}
+
+func TestRenderWithPush(t *testing.T) {
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+	f.SetParameter("templates", map[string]*template.Template{
+		"page":   template.Must(template.New("page").Parse(`<link rel="stylesheet" href="/app.css"><img src="/{{.img}}">{{.user}}`)),
+		"broken": template.Must(template.New("broken").Parse(`{{.user.Missing}}`)),
+	})
+
+	f.Use(func(c *Context) {
+		c.Set("user", "floki")
+		c.Next()
+	})
+	f.GET("/:tpl", func(c *Context) {
+		c.RenderWithPush(c.Params.ByName("tpl"), Model{"img": "logo.png", "user": "unused"})
+	})
+
+	w := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	f.ServeHTTP(w, httptest.NewRequest("GET", "/page", nil))
+
+	if w.Code != 200 || w.Body.String() != `<link rel="stylesheet" href="/app.css"><img src="/logo.png">floki` {
+		t.Errorf("unexpected response %d: %s", w.Code, w.Body)
+	}
+	if !reflect.DeepEqual(w.pushed, []string{"/logo.png", "/app.css"}) {
+		t.Errorf("unexpected pushed assets %v", w.pushed)
+	}
+
+	for _, name := range []string{"broken", "missing"} {
+		w = &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		f.ServeHTTP(w, httptest.NewRequest("GET", "/"+name, nil))
+
+		if w.Code != 504 || len(w.pushed) != 0 {
+			t.Errorf("%s: error should be sent without push, got %d, pushed %v", name, w.Code, w.pushed)
+		}
+	}
+
+	// connections without push support get the page only
+	rec := httptest.NewRecorder()
+	f.ServeHTTP(rec, httptest.NewRequest("GET", "/page", nil))
+	if rec.Code != 200 || !strings.HasSuffix(rec.Body.String(), "floki") {
+		t.Errorf("page should be rendered without push, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestEnableH2C(t *testing.T) {
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+	f.GET("/proto", func(c *Context) {
+		c.Send(200, c.Request.Proto)
+	})
+
+	server := f.newServer("", f)
+	enableH2C(server)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(l)
+	defer server.Close()
+
+	for _, unencryptedHTTP2 := range []bool{false, true} {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(!unencryptedHTTP2)
+		protocols.SetUnencryptedHTTP2(unencryptedHTTP2)
+
+		client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+		res, err := client.Get("http://" + l.Addr().String() + "/proto")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+
+		expected := "HTTP/1.1"
+		if unencryptedHTTP2 {
+			expected = "HTTP/2.0"
+		}
+		if string(body) != expected {
+			t.Errorf("expected %s, got %s", expected, body)
+		}
+	}
+}
@@ -114,15 +114,14 @@ func Recovery() HandlerFunc {
 	return func(c *Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				log := c.Logger()
 				stack := stack(3)
-				log.Printf("PANIC: %s\n%s", err, stack)
+				c.Log().Error("PANIC", "error", fmt.Sprint(err), "stack", string(stack))
 
 				res := c.Writer
 
 				// respond with panic message while in development mode
 				var body []byte
-				if Env == Dev {
+				if c.Floki.Env == Dev {
 					res.Header().Set("Content-Type", "text/html")
 					body = []byte(fmt.Sprintf(panicHtml, err, err, stack))
 				}
@@ -14,10 +14,13 @@ type (
 	ResponseWriter interface {
 		http.ResponseWriter
 		http.Flusher
+		http.Pusher
 		// Status returns the status code of the response or 0 if the response has not been written.
 		Status() int
 		// Written returns whether or not the ResponseWriter has been written.
 		Written() bool
+		// Size returns the number of bytes written to the response body.
+		Size() int
 
 		reset(http.ResponseWriter)
 		setStatus(int)
@@ -26,6 +29,7 @@ type (
 	responseWriter struct {
 		http.ResponseWriter
 		status  int
+		size    int
 		written bool
 	}
 )
@@ -33,6 +37,7 @@ type (
 func (w *responseWriter) reset(writer http.ResponseWriter) {
 	w.ResponseWriter = writer
 	w.status = 0
+	w.size = 0
 	w.written = false
 }
 
@@ -48,6 +53,16 @@ func (w *responseWriter) Written() bool {
 	return w.written
 }
 
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
 func (w *responseWriter) WriteHeader(code int) {
 	w.status = code
 	w.written = true
@@ -58,6 +73,15 @@ func (rw *responseWriter) CloseNotify() <-chan bool {
 	return rw.ResponseWriter.(http.CloseNotifier).CloseNotify()
 }
 
+// Push initiates HTTP/2 server push. http.ErrNotSupported is returned if the client connection doesn't support it.
+func (rw *responseWriter) Push(target string, opts *http.PushOptions) error {
+	pusher, ok := rw.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return pusher.Push(target, opts)
+}
+
 func (rw *responseWriter) Flush() {
 	flusher, ok := rw.ResponseWriter.(http.Flusher)
 	if ok {
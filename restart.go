@@ -0,0 +1,155 @@
+package floki
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const (
+	// readyFdEnv is the descriptor the child process writes readyMessage to once it accepts connections
+	readyFdEnv   = "FLOKI_READY_FD"
+	readyMessage = "ready\n"
+)
+
+// Restart replaces the running process with a new one without dropping connections.
+// The new process inherits listeners and reports back when it's ready to accept connections.
+// Only then this process stops accepting, drains in-flight requests and exits.
+// If the new process fails to start or doesn't become ready within "restartTimeout"
+// from config (30s by default), it's killed and this process keeps serving.
+//
+// SIGUSR2 calls Restart. It can only be called while the server is running.
+func (f *Floki) Restart() error {
+	if f.restartFunc == nil {
+		return errors.New("server is not running")
+	}
+
+	if !f.restarting.CompareAndSwap(false, true) {
+		return errors.New("restart is already in progress")
+	}
+	defer f.restarting.Store(false)
+
+	if f.draining.Load() {
+		return errors.New("server is shutting down")
+	}
+
+	return f.restartFunc()
+}
+
+// restart spawns child process which takes over listeners and pid file and waits for its readiness
+func (f *Floki) restart(specs []*listenerSpec, pid *pidFile) error {
+	files, names, err := listenerFiles(specs)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyReader.Close()
+
+	executable, err := os.Executable()
+	if err != nil {
+		readyWriter.Close()
+		return err
+	}
+
+	args := f.restartArgs
+	if args == nil {
+		args = os.Args[1:]
+	}
+
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, readyWriter)
+	cmd.Env = append(os.Environ(),
+		inheritedListenersEnv+"="+names,
+		readyFdEnv+"="+strconv.Itoa(listenFdsStart+len(files)),
+	)
+
+	// the lock is held by both processes until this one exits
+	if pid != nil {
+		cmd.Env = append(cmd.Env, pidFileFdEnv+"="+strconv.Itoa(listenFdsStart+len(cmd.ExtraFiles)))
+		cmd.ExtraFiles = append(cmd.ExtraFiles, pid.file)
+	}
+
+	err = cmd.Start()
+
+	// the child has its own copy now, so EOF is read when it exits
+	readyWriter.Close()
+
+	if err != nil {
+		return fmt.Errorf("can't start new process %s: %v", executable, err)
+	}
+
+	childPid := cmd.Process.Pid
+	f.log.Info("new process started, waiting until it's ready", "pid", childPid)
+
+	// reap the child if it exits or gets killed while this process is still running
+	go cmd.Wait()
+
+	ready := make(chan error, 1)
+	go func() {
+		line, err := bufio.NewReader(readyReader).ReadString('\n')
+		if line != readyMessage {
+			err = fmt.Errorf("new process didn't report readiness: %v", err)
+		}
+		ready <- err
+	}()
+
+	select {
+	case err = <-ready:
+	case <-time.After(f.Config.Duration("restartTimeout", 30*time.Second)):
+		err = errors.New("timeout waiting for new process to become ready")
+	}
+
+	if err != nil {
+		cmd.Process.Kill()
+
+		// new process could have written its pid before failing
+		if writeErr := pid.write(f.log); writeErr != nil {
+			f.log.Error("can't write pid to file", "error", writeErr)
+		}
+
+		return fmt.Errorf("new process %d failed: %v", childPid, err)
+	}
+
+	f.log.Info("new process is ready, draining", "pid", childPid)
+	go f.shutdownWithTimeout()
+
+	return nil
+}
+
+// notifyParent tells the parent process that listeners were taken over.
+// It does nothing if this process was not started by Restart.
+func notifyParent() error {
+	value := os.Getenv(readyFdEnv)
+	if value == "" {
+		return nil
+	}
+
+	os.Unsetenv(readyFdEnv)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %q", readyFdEnv, value)
+	}
+
+	pipe := os.NewFile(uintptr(fd), "ready")
+	defer pipe.Close()
+
+	_, err = pipe.WriteString(readyMessage)
+	return err
+}
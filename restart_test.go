@@ -0,0 +1,224 @@
+package floki
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const restartHelperEnv = "FLOKI_RESTART_HELPER"
+
+// newRestartApp creates application which responds with its pid
+func newRestartApp(pidFile string) *Floki {
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+	f.Config = ConfigMap{}
+	f.restartArgs = []string{"-test.run=^TestRestartHelperProcess$"}
+
+	f.GET("/pid", func(c *Context) {
+		time.Sleep(10 * time.Millisecond)
+		c.Send(200, strconv.Itoa(os.Getpid()))
+	})
+
+	return f
+}
+
+// TestRestartHelperProcess is the new process started by Restart in tests below
+func TestRestartHelperProcess(t *testing.T) {
+	mode := os.Getenv(restartHelperEnv)
+	if mode == "" {
+		t.Skip("started by restart tests only")
+	}
+
+	if mode == "fail" {
+		os.Exit(1)
+	}
+
+	pidFile := os.Getenv("FLOKI_TEST_PID_FILE")
+	// listeners are inherited from the parent, so the address is not used
+	f := newRestartApp(pidFile)
+	f.serve("unused:0", f, pidFile, nil)
+}
+
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	return l.Addr().String()
+}
+
+func getPid(url string) (int, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(string(body))
+}
+
+func startRestartApp(t *testing.T, mode string) (*Floki, string, string, chan error) {
+	pidFile := filepath.Join(t.TempDir(), "floki.pid")
+	os.Setenv(restartHelperEnv, mode)
+	os.Setenv("FLOKI_TEST_PID_FILE", pidFile)
+	t.Cleanup(func() {
+		os.Unsetenv(restartHelperEnv)
+		os.Unsetenv("FLOKI_TEST_PID_FILE")
+	})
+
+	addr := freeAddr(t)
+	f := newRestartApp(pidFile)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- f.serve(addr, f, pidFile, nil)
+	}()
+
+	url := "http://" + addr + "/pid"
+	for i := 0; ; i++ {
+		if _, err := getPid(url); err == nil {
+			break
+		} else if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return f, url, pidFile, done
+}
+
+func readPidFile(t *testing.T, pidFile string) int {
+	data, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pid
+}
+
+func TestRestart(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns processes")
+	}
+
+	f, url, pidFile, done := startRestartApp(t, "serve")
+
+	if pid := readPidFile(t, pidFile); pid != os.Getpid() {
+		t.Fatalf("pid file should contain %d, got %d", os.Getpid(), pid)
+	}
+
+	// keep clients busy during restart, none of the requests may fail
+	var failed, served int64
+	stop := make(chan struct{})
+	clientsDone := make(chan struct{})
+	go func() {
+		defer close(clientsDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			if _, err := getPid(url); err != nil {
+				atomic.AddInt64(&failed, 1)
+				t.Log("request failed:", err)
+			} else {
+				atomic.AddInt64(&served, 1)
+			}
+		}
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if err := f.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("old process didn't stop serving")
+	}
+
+	childPid := readPidFile(t, pidFile)
+	if childPid == os.Getpid() {
+		t.Fatal("pid file was not taken over by new process")
+	}
+	defer syscall.Kill(childPid, syscall.SIGTERM)
+
+	if _, err := lockPidFile(pidFile); err == nil {
+		t.Error("pid file lock should be held by new process")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	<-clientsDone
+
+	if failed > 0 {
+		t.Errorf("%d of %d requests failed during restart", failed, failed+served)
+	}
+
+	pid, err := getPid(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pid != childPid {
+		t.Errorf("requests should be served by new process %d, served by %d", childPid, pid)
+	}
+}
+
+func TestRestartFailedChild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns processes")
+	}
+
+	f, url, pidFile, done := startRestartApp(t, "fail")
+
+	if err := f.Restart(); err == nil {
+		t.Fatal("restart should fail when new process exits")
+	}
+
+	pid, err := getPid(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pid != os.Getpid() {
+		t.Errorf("old process should keep serving")
+	}
+
+	f.Shutdown(context.Background())
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+		t.Error("pid file should be removed on shutdown")
+	}
+}
@@ -0,0 +1,264 @@
+package floki
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat has fixed width, so names of backups sort chronologically
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingFile is an io.Writer which writes to a log file and moves it aside once it grows
+// beyond MaxSize or every Interval. Rotated files are named after the original file with
+// a timestamp inserted before the extension: floki-20150102T150405.000.log
+type RotatingFile struct {
+	Filename string
+	// MaxSize in bytes triggers rotation when exceeded. Zero disables size based rotation.
+	MaxSize int64
+	// Interval triggers rotation at every multiple of it. Zero disables time based rotation.
+	Interval time.Duration
+	// MaxBackups is the number of rotated files to keep. Zero keeps all of them.
+	MaxBackups int
+	// MaxAge removes rotated files older than that. Zero keeps files regardless of their age.
+	MaxAge time.Duration
+	// Compress gzips rotated files.
+	Compress bool
+	// OnOpen is called every time a new file is opened.
+	OnOpen func(file *os.File)
+
+	mu        sync.Mutex
+	file      *os.File
+	size      int64
+	rotateAt  time.Time
+	cleanupMu sync.Mutex
+}
+
+// Open opens the log file for appending. It's called by the first Write if needed.
+func (r *RotatingFile) Open() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.open()
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.shouldRotate(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate moves current file aside and starts a new one.
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.rotate()
+}
+
+// Reopen closes and opens the file again. Use it after the file was moved by external tools like logrotate.
+func (r *RotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.close(); err != nil {
+		return err
+	}
+
+	return r.open()
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.close()
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.Filename, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0660)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+
+	if r.Interval > 0 {
+		r.rotateAt = time.Now().Truncate(r.Interval).Add(r.Interval)
+	}
+
+	if r.OnOpen != nil {
+		r.OnOpen(file)
+	}
+
+	return nil
+}
+
+func (r *RotatingFile) close() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) shouldRotate(writeLen int64) bool {
+	if r.MaxSize > 0 && r.size > 0 && r.size+writeLen > r.MaxSize {
+		return true
+	}
+
+	return r.Interval > 0 && !time.Now().Before(r.rotateAt)
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.close(); err != nil {
+		return err
+	}
+
+	backup := r.backupName(time.Now())
+	if err := os.Rename(r.Filename, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	go r.cleanup(backup)
+
+	return nil
+}
+
+// backupName returns name for the file rotated at t. Several rotations can happen within
+// the same timestamp, so the time is moved forward until the name is not taken by previous backup.
+func (r *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(r.Filename)
+	base := strings.TrimSuffix(r.Filename, ext)
+
+	for {
+		name := base + "-" + t.Format(backupTimeFormat) + ext
+		if !fileExists(name) && !fileExists(name+".gz") {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func fileExists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// cleanup compresses the latest backup and removes backups exceeding MaxBackups or MaxAge
+func (r *RotatingFile) cleanup(backup string) {
+	r.cleanupMu.Lock()
+	defer r.cleanupMu.Unlock()
+
+	if r.Compress {
+		if err := compressFile(backup); err == nil {
+			os.Remove(backup)
+		}
+	}
+
+	if r.MaxBackups == 0 && r.MaxAge == 0 {
+		return
+	}
+
+	backups := r.backups()
+
+	// timestamps in file names sort chronologically, newest first
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	for idx, name := range backups {
+		remove := r.MaxBackups > 0 && idx >= r.MaxBackups
+
+		if !remove && r.MaxAge > 0 {
+			info, err := os.Stat(name)
+			remove = err == nil && time.Since(info.ModTime()) > r.MaxAge
+		}
+
+		if remove {
+			os.Remove(name)
+		}
+	}
+}
+
+// backups returns backups of the file, compressed or not. Other files with similar names,
+// like app-access.log next to app.log, are skipped.
+func (r *RotatingFile) backups() []string {
+	ext := filepath.Ext(r.Filename)
+	base := strings.TrimSuffix(r.Filename, ext)
+
+	matches, _ := filepath.Glob(base + "-*")
+
+	backups := make([]string, 0, len(matches))
+	for _, name := range matches {
+		stamp := strings.TrimPrefix(strings.TrimSuffix(name, ".gz"), base+"-")
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+
+		if _, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ext)); err == nil {
+			backups = append(backups, name)
+		}
+	}
+
+	return backups
+}
+
+func compressFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(name+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(name + ".gz")
+	}
+
+	return err
+}
@@ -0,0 +1,256 @@
+package floki
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  RotatingFile
+		run   func(t *testing.T, r *RotatingFile)
+		check func(t *testing.T, r *RotatingFile)
+	}{
+		{
+			name: "size",
+			file: RotatingFile{MaxSize: 10},
+			run: func(t *testing.T, r *RotatingFile) {
+				writeEntries(t, r, "entry-1\n", "entry-2\n", "entry-3\n", "entry-4\n", "entry-5\n")
+			},
+			check: func(t *testing.T, r *RotatingFile) {
+				// rotations within the same second don't overwrite each other
+				if backups := waitBackups(t, r, 4); len(backups) != 4 {
+					t.Fatalf("expected 4 backups, got %v", backups)
+				}
+
+				if all := readLogFiles(t, r); all != "entry-1\nentry-2\nentry-3\nentry-4\nentry-5\n" {
+					t.Errorf("entries were lost: %q", all)
+				}
+			},
+		},
+		{
+			name: "interval",
+			file: RotatingFile{Interval: time.Hour},
+			run: func(t *testing.T, r *RotatingFile) {
+				writeEntries(t, r, "before\n")
+				r.rotateAt = time.Now().Add(-time.Second)
+				writeEntries(t, r, "after\n")
+			},
+			check: func(t *testing.T, r *RotatingFile) {
+				backups := waitBackups(t, r, 1)
+				if len(backups) != 1 || readFile(t, backups[0]) != "before\n" {
+					t.Errorf("expected one backup with old entry, got %v", backups)
+				}
+
+				if current := readFile(t, r.Filename); current != "after\n" {
+					t.Errorf("unexpected current file %q", current)
+				}
+
+				if !r.rotateAt.After(time.Now()) {
+					t.Errorf("next rotation should be scheduled, got %v", r.rotateAt)
+				}
+			},
+		},
+		{
+			name: "max backups",
+			file: RotatingFile{MaxBackups: 2},
+			run: func(t *testing.T, r *RotatingFile) {
+				for i := 0; i < 4; i++ {
+					writeEntries(t, r, "entry\n")
+					if err := r.Rotate(); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			check: func(t *testing.T, r *RotatingFile) {
+				if backups := waitBackups(t, r, 2); len(backups) != 2 {
+					t.Errorf("expected 2 backups to be kept, got %v", backups)
+				}
+			},
+		},
+		{
+			name: "max age",
+			file: RotatingFile{MaxAge: time.Hour},
+			run: func(t *testing.T, r *RotatingFile) {
+				old := r.backupName(time.Now().Add(-48 * time.Hour))
+				if err := ioutil.WriteFile(old, []byte("old\n"), 0660); err != nil {
+					t.Fatal(err)
+				}
+				past := time.Now().Add(-48 * time.Hour)
+				os.Chtimes(old, past, past)
+
+				writeEntries(t, r, "entry\n")
+				if err := r.Rotate(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			check: func(t *testing.T, r *RotatingFile) {
+				backups := waitBackups(t, r, 1)
+				if len(backups) != 1 || readFile(t, backups[0]) != "entry\n" {
+					t.Errorf("expected only fresh backup to be kept, got %v", backups)
+				}
+			},
+		},
+		{
+			name: "other files",
+			file: RotatingFile{MaxBackups: 1, MaxAge: time.Hour},
+			run: func(t *testing.T, r *RotatingFile) {
+				// files of other logs in the same directory look like backups
+				past := time.Now().Add(-48 * time.Hour)
+				for _, name := range otherLogFiles(r) {
+					if err := ioutil.WriteFile(name, []byte("other\n"), 0660); err != nil {
+						t.Fatal(err)
+					}
+					os.Chtimes(name, past, past)
+				}
+
+				for i := 0; i < 2; i++ {
+					writeEntries(t, r, "entry\n")
+					if err := r.Rotate(); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			check: func(t *testing.T, r *RotatingFile) {
+				if backups := waitBackups(t, r, 1); len(backups) != 1 {
+					t.Errorf("expected 1 backup to be kept, got %v", backups)
+				}
+
+				for _, name := range otherLogFiles(r) {
+					if !fileExists(name) {
+						t.Errorf("%s is not a backup and should be kept", name)
+					}
+				}
+			},
+		},
+		{
+			name: "compress",
+			file: RotatingFile{Compress: true},
+			run: func(t *testing.T, r *RotatingFile) {
+				writeEntries(t, r, "compressed\n")
+				if err := r.Rotate(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			check: func(t *testing.T, r *RotatingFile) {
+				backups := waitBackups(t, r, 1)
+				if len(backups) != 1 || !strings.HasSuffix(backups[0], ".log.gz") {
+					t.Fatalf("expected compressed backup, got %v", backups)
+				}
+
+				if content := readFile(t, backups[0]); content != "compressed\n" {
+					t.Errorf("unexpected content of compressed backup %q", content)
+				}
+			},
+		},
+		{
+			name: "reopen",
+			run: func(t *testing.T, r *RotatingFile) {
+				writeEntries(t, r, "moved\n")
+
+				// like logrotate moving the file aside
+				if err := os.Rename(r.Filename, r.Filename+".1"); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := r.Reopen(); err != nil {
+					t.Fatal(err)
+				}
+				writeEntries(t, r, "reopened\n")
+			},
+			check: func(t *testing.T, r *RotatingFile) {
+				if moved := readFile(t, r.Filename+".1"); moved != "moved\n" {
+					t.Errorf("unexpected moved file %q", moved)
+				}
+
+				if current := readFile(t, r.Filename); current != "reopened\n" {
+					t.Errorf("unexpected reopened file %q", current)
+				}
+			},
+		},
+	}
+
+	for i := range tests {
+		test := &tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			r := &test.file
+			r.Filename = filepath.Join(t.TempDir(), "floki.log")
+			defer r.Close()
+
+			test.run(t, r)
+			test.check(t, r)
+		})
+	}
+}
+
+func writeEntries(t *testing.T, r *RotatingFile, entries ...string) {
+	for _, entry := range entries {
+		if _, err := r.Write([]byte(entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// waitBackups waits for cleanup running in background to leave expected number of backups
+func waitBackups(t *testing.T, r *RotatingFile, expected int) []string {
+	var backups []string
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		r.cleanupMu.Lock()
+		backups = r.backups()
+		r.cleanupMu.Unlock()
+
+		if len(backups) == expected && (!r.Compress || strings.HasSuffix(backups[0], ".gz")) {
+			break
+		}
+	}
+
+	sort.Strings(backups)
+	return backups
+}
+
+// otherLogFiles returns names of files which are not backups of r, but match its backup pattern
+func otherLogFiles(r *RotatingFile) []string {
+	base := strings.TrimSuffix(r.Filename, ".log")
+	return []string{base + "-access.log", base + "-access.log.gz", base + "-20240101.log", base + "-20240101T000000.000.txt"}
+}
+
+// readLogFiles returns content of backups and the current file in order of writing
+func readLogFiles(t *testing.T, r *RotatingFile) string {
+	backups := r.backups()
+	sort.Strings(backups)
+
+	var all string
+	for _, name := range append(backups, r.Filename) {
+		all += readFile(t, name)
+	}
+	return all
+}
+
+func readFile(t *testing.T, name string) string {
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var in io.Reader = file
+	if strings.HasSuffix(name, ".gz") {
+		if in, err = gzip.NewReader(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -12,15 +12,35 @@ type RouteHandler struct {
 	path             string
 	handlers         []HandlerFunc
 	handlersCombined []HandlerFunc
+	listeners        []string
 }
 
 func (r RouteHandler) Handle(w http.ResponseWriter, req *http.Request, params router.Params) {
+	if !r.servedOn(listenerName(req)) {
+		r.floki.handle404(w, req)
+		return
+	}
+
 	c := r.floki.createContext(w, req, params, r.handlersCombined)
 	c.Next()
 	c.beforeRelease()
 	r.floki.contextPool.Put(c)
 }
 
+// servedOn checks if the route is reachable on the named listener
+func (r RouteHandler) servedOn(listener string) bool {
+	if len(r.listeners) == 0 {
+		return true
+	}
+
+	for _, name := range r.listeners {
+		if name == listener {
+			return true
+		}
+	}
+	return false
+}
+
 func (r RouteHandler) HandleWithContext(c *Context, params router.Params) {
 	c2 := r.floki.createContext(c.Writer, c.Request, params, r.handlers)
 	c2.Next()
@@ -46,6 +66,7 @@ func (group *RouterGroup) Handle(method, p string, handlers []HandlerFunc) {
 		p,
 		handlers,
 		group.combineHandlers(handlers),
+		group.listeners,
 	}
 
 	group.floki.router.Handle(method, p, rh)
@@ -105,7 +126,7 @@ func (group *RouterGroup) Static(p, root string) {
 		headers := writer.Header()
 
 		// in production environment static content needs to be cached by browsers & proxies
-		if Env == Prod {
+		if group.floki.Env == Prod {
 			// cache for 3 months
 			headers.Set("Expires", time.Now().AddDate(0, 3, 0).Format(http.TimeFormat))
 
@@ -136,9 +157,17 @@ func (group *RouterGroup) Use(middlewares ...HandlerFunc) {
 func (group *RouterGroup) Group(component string, handlers ...HandlerFunc) *RouterGroup {
 	prefix := path.Join(group.prefix, component)
 	return &RouterGroup{
-		Handlers: group.combineHandlers(handlers),
-		parent:   group,
-		prefix:   prefix,
-		floki:    group.floki,
+		Handlers:  group.combineHandlers(handlers),
+		parent:    group,
+		prefix:    prefix,
+		floki:     group.floki,
+		listeners: group.listeners,
 	}
 }
+
+// BindListeners makes routes of the group reachable only on the named listeners, see Floki.AddListener.
+// Requests coming through other listeners get 404. Routes added to the group before the call are not affected.
+func (group *RouterGroup) BindListeners(names ...string) *RouterGroup {
+	group.listeners = names
+	return group
+}
@@ -0,0 +1,48 @@
+package floki
+
+import (
+	"log"
+	"net/http"
+)
+
+// newServer creates http.Server with timeouts and limits from config:
+//     readTimeout       - time to read the whole request including body, e.g. "30s"
+//     readHeaderTimeout - time to read request headers
+//     writeTimeout      - time to write the response
+//     idleTimeout       - time to keep idle keep-alive connection open
+//     maxHeaderBytes    - limit of request headers size, e.g. "1MB"
+//     keepAlive         - enables HTTP keep-alive, true by default
+//     h2c               - accepts HTTP/2 without TLS (prior knowledge), false by default
+// Timeouts are disabled unless configured.
+func (f *Floki) newServer(addr string, handler http.Handler) *http.Server {
+	conf := f.Config
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       conf.Duration("readTimeout", 0),
+		ReadHeaderTimeout: conf.Duration("readHeaderTimeout", 0),
+		WriteTimeout:      conf.Duration("writeTimeout", 0),
+		IdleTimeout:       conf.Duration("idleTimeout", 0),
+		MaxHeaderBytes:    int(conf.Bytes("maxHeaderBytes", 0)),
+		ErrorLog:          log.New(&logWriter{log: f.log, level: LevelWarn}, "", 0),
+	}
+
+	server.SetKeepAlivesEnabled(conf.Bool("keepAlive", true))
+
+	if conf.Bool("h2c", false) {
+		enableH2C(server)
+	}
+
+	return server
+}
+
+// enableH2C makes server accept unencrypted HTTP/2 next to HTTP/1 and HTTP/2 over TLS
+func enableH2C(server *http.Server) {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	server.Protocols = protocols
+}
@@ -0,0 +1,91 @@
+package floki
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewServerFromConfig(t *testing.T) {
+	f := Must(New())
+	f.Config = testConfig(t, `{
+		"readTimeout": "30s",
+		"readHeaderTimeout": "5s",
+		"writeTimeout": "1m",
+		"idleTimeout": "2m",
+		"maxHeaderBytes": "1MB",
+		"keepAlive": false
+	}`)
+
+	server := f.newServer(":3000", f)
+
+	if server.Addr != ":3000" || server.Handler != f {
+		t.Errorf("unexpected address %s or handler", server.Addr)
+	}
+
+	timeouts := map[string][2]time.Duration{
+		"readTimeout":       {server.ReadTimeout, 30 * time.Second},
+		"readHeaderTimeout": {server.ReadHeaderTimeout, 5 * time.Second},
+		"writeTimeout":      {server.WriteTimeout, time.Minute},
+		"idleTimeout":       {server.IdleTimeout, 2 * time.Minute},
+	}
+	for key, timeout := range timeouts {
+		if timeout[0] != timeout[1] {
+			t.Errorf("%s: expected %s, got %s", key, timeout[1], timeout[0])
+		}
+	}
+
+	if server.MaxHeaderBytes != 1<<20 {
+		t.Errorf("maxHeaderBytes: expected 1MB, got %d", server.MaxHeaderBytes)
+	}
+
+	if serverKeepsAlive(t, server) {
+		t.Error("keepAlive: connections should be closed after response")
+	}
+
+	var conf flokiConfig
+	if err := f.Config.DecodeAll(&conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.MaxHeaderBytes != 1<<20 || conf.KeepAlive {
+		t.Errorf("schema should accept the config, got %d, %v", conf.MaxHeaderBytes, conf.KeepAlive)
+	}
+}
+
+func TestNewServerDefaults(t *testing.T) {
+	f := Must(New())
+	f.Config = testConfig(t, `{"maxHeaderBytes": 4096}`)
+
+	server := f.newServer(":3000", f)
+
+	if server.ReadTimeout != 0 || server.ReadHeaderTimeout != 0 || server.WriteTimeout != 0 || server.IdleTimeout != 0 {
+		t.Error("timeouts should be disabled unless configured")
+	}
+
+	if !serverKeepsAlive(t, server) {
+		t.Error("keep-alive should be enabled by default")
+	}
+
+	if server.MaxHeaderBytes != 4096 {
+		t.Errorf("maxHeaderBytes should accept number of bytes, got %d", server.MaxHeaderBytes)
+	}
+}
+
+// serverKeepsAlive serves a request and checks if the connection is kept open
+func serverKeepsAlive(t *testing.T, server *http.Server) bool {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(l)
+	defer server.Close()
+
+	res, err := http.Get("http://" + l.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	return !res.Close
+}
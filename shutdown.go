@@ -0,0 +1,116 @@
+package floki
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// InFlight returns the number of requests being handled right now.
+func (f *Floki) InFlight() int64 {
+	return f.inFlight.Load()
+}
+
+// Shutdown gracefully stops the server. Readiness endpoint starts failing and OnDrain hooks
+// are called, then Shutdown waits for "drainDelay" from config, so load balancers can notice
+// the instance is going away, closes the listener and waits for in-flight requests to complete. Idle keep-alive
+// connections are closed right away. When ctx expires before all requests are done,
+// remaining connections are closed forcibly and ctx error is returned.
+// OnShutdown hooks are called in both cases.
+//
+// Calling Shutdown more than once waits for the first call to finish.
+func (f *Floki) Shutdown(ctx context.Context) error {
+	if !f.draining.CompareAndSwap(false, true) {
+		select {
+		case <-f.stopped:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	defer close(f.stopped)
+
+	// drain hook errors are logged, they don't stop the shutdown
+	f.runHooks(stageDrain)
+
+	if delay := f.Config.Duration("drainDelay", 0); delay > 0 {
+		f.log.Info("draining, waiting before closing listener", "delay", delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+
+	servers := f.servers()
+	f.log.Info("closing listeners and draining connections", "inFlight", f.InFlight())
+
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			server.SetKeepAlivesEnabled(false)
+
+			err := server.Shutdown(ctx)
+			if err != nil {
+				server.Close()
+			}
+			errs <- err
+		}(server)
+	}
+
+	var err error
+	for range servers {
+		if serverErr := <-errs; serverErr != nil && err == nil {
+			err = serverErr
+		}
+	}
+
+	if err != nil {
+		f.log.Warn("drain timeout expired, remaining connections were closed", "inFlight", f.InFlight(), "error", err)
+	}
+
+	f.stopWatching()
+
+	if hooksErr := f.runHooks(stageShutdown); hooksErr != nil && err == nil {
+		err = hooksErr
+	}
+
+	return err
+}
+
+// addServer registers server to be stopped by Shutdown. Servers are refused once Shutdown
+// has started, as it may have taken the list already.
+func (f *Floki) addServer(server *http.Server) bool {
+	f.serversMu.Lock()
+	defer f.serversMu.Unlock()
+
+	if f.draining.Load() {
+		return false
+	}
+
+	f.serverList = append(f.serverList, server)
+	return true
+}
+
+func (f *Floki) servers() []*http.Server {
+	f.serversMu.Lock()
+	defer f.serversMu.Unlock()
+
+	return append([]*http.Server(nil), f.serverList...)
+}
+
+// drainTimeout is the time given to in-flight requests when shutting down on a signal
+func (f *Floki) drainTimeout() time.Duration {
+	return f.Config.Duration("drainTimeout", 30*time.Second)
+}
+
+// shutdownWithTimeout calls Shutdown limited by "drainTimeout" from config
+func (f *Floki) shutdownWithTimeout() {
+	ctx, cancel := context.WithTimeout(context.Background(), f.drainTimeout())
+	defer cancel()
+
+	if err := f.Shutdown(ctx); err != nil {
+		f.log.Error("shutdown was not graceful", "error", err)
+	}
+}
@@ -0,0 +1,185 @@
+package floki
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// startShutdownApp serves application through listenerServer and serveListener like serve does
+func startShutdownApp(t *testing.T, release chan struct{}) (*Floki, string) {
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+
+	f.GET("/fast", func(c *Context) {
+		c.Send(200, "fast")
+	})
+	f.GET("/slow", func(c *Context) {
+		<-release
+		c.Send(200, "slow")
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := &listenerSpec{name: DefaultListener, addr: l.Addr().String(), handler: f, listener: l}
+	go f.serveListener(f.listenerServer(spec), spec)
+
+	return f, "http://" + l.Addr().String()
+}
+
+// waitInFlight waits until n requests are being handled
+func waitInFlight(t *testing.T, f *Floki, n int64) {
+	for deadline := time.Now().Add(2 * time.Second); f.InFlight() != n; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d requests in flight, got %d", n, f.InFlight())
+		}
+	}
+}
+
+func TestShutdownWaitsForInFlightRequests(t *testing.T) {
+	release := make(chan struct{})
+	f, url := startShutdownApp(t, release)
+
+	responses := make(chan *http.Response, 1)
+	go func() {
+		res, err := http.Get(url + "/slow")
+		if err != nil {
+			t.Error(err)
+		}
+		responses <- res
+	}()
+
+	waitInFlight(t, f, 1)
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- f.Shutdown(context.Background())
+	}()
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown should wait for in-flight request, returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	if res := <-responses; res == nil || res.StatusCode != 200 {
+		t.Errorf("in-flight request should complete, got %v", res)
+	}
+
+	if err := <-shutdown; err != nil {
+		t.Errorf("graceful shutdown should succeed, got %v", err)
+	}
+
+	if f.InFlight() != 0 {
+		t.Errorf("no requests should be in flight, got %d", f.InFlight())
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	f, url := startShutdownApp(t, release)
+
+	requestErr := make(chan error, 1)
+	go func() {
+		res, err := http.Get(url + "/slow")
+		if err == nil {
+			res.Body.Close()
+		}
+		requestErr <- err
+	}()
+
+	waitInFlight(t, f, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := f.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected ctx error, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown should return at the deadline, took %s", elapsed)
+	}
+
+	// connection of the stuck request is closed forcibly
+	select {
+	case err := <-requestErr:
+		if err == nil {
+			t.Error("stuck request should fail")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("connection of stuck request should be closed")
+	}
+}
+
+func TestShutdownClosesIdleConnections(t *testing.T) {
+	f, url := startShutdownApp(t, make(chan struct{}))
+
+	conn, err := net.Dial("tcp", url[len("http://"):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// keep-alive connection stays open after the response
+	conn.Write([]byte("GET /fast HTTP/1.1\r\nHost: test\r\n\r\n"))
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(res.Body)
+	res.Body.Close()
+
+	if err = f.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err = reader.ReadByte(); err == nil {
+		t.Error("idle connection should be closed")
+	} else if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		t.Error("idle connection was left open")
+	}
+}
+
+func TestShutdownBeforeServing(t *testing.T) {
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+
+	if err := f.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// listener opened before the shutdown is closed instead of being served forever
+	spec := &listenerSpec{name: DefaultListener, addr: l.Addr().String(), handler: f, listener: l}
+	if server := f.listenerServer(spec); server != nil {
+		t.Fatal("server should not be registered after shutdown")
+	}
+
+	if err = f.serveListener(nil, spec); err != http.ErrServerClosed {
+		t.Errorf("expected ErrServerClosed, got %v", err)
+	}
+
+	if _, err = net.Dial("tcp", l.Addr().String()); err == nil {
+		t.Error("listener should be closed")
+	}
+}
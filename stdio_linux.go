@@ -0,0 +1,19 @@
+package floki
+
+import (
+	"os"
+	"syscall"
+)
+
+// redirectStdio points file descriptors of standard output and error to file, so output
+// of the runtime and of code writing to os.Stdout lands in the log file. os.Stdout and
+// os.Stderr are left intact, they keep using descriptors 1 and 2. Descriptors stay valid
+// while the file is rotated, they refer to the previous file until the next one is opened.
+func redirectStdio(file *os.File) error {
+	for _, fd := range []int{1, 2} {
+		if err := syscall.Dup3(int(file.Fd()), fd, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package floki
+
+import (
+	"os"
+	"syscall"
+)
+
+// redirectStdio points file descriptors of standard output and error to file, see stdio_linux.go
+func redirectStdio(file *os.File) error {
+	for _, fd := range []int{1, 2} {
+		if err := syscall.Dup2(int(file.Fd()), fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
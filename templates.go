@@ -1,192 +1,124 @@
 package floki
 
 import (
+	"fmt"
 	"github.com/go-floki/jade"
-	"github.com/howeyc/fsnotify"
 	"html/template"
-	"log"
 	"os"
 	"path/filepath"
-	"strings"
-	"time"
+	"reflect"
+	"sync"
 )
 
-var templatesData struct {
+// templateState keeps compiled templates of the application, so they can be recompiled when changed
+type templateState struct {
 	compiledTemplates map[string]*template.Template
 	directory         string
 	compileOptions    jade.Options
+
+	mu           sync.Mutex
+	watchedDirs  []string
+	stopWatching func()
 }
 
-func (f *Floki) compileTemplates(templatesDir string, logger *log.Logger) map[string]*template.Template {
+func (f *Floki) compileTemplates(templatesDir string) (map[string]*template.Template, error) {
 	var compileOptions jade.Options
 
-	if Env == Prod {
+	if f.Env == Prod {
 		compileOptions = jade.Options{true, true}
 	} else {
 		compileOptions = jade.Options{false, false}
-		f.Logger().Printf("Compiling templates in: %s\n", templatesDir)
+		f.log.Debug("compiling templates", "dir", templatesDir)
 	}
 
 	//
 	templates, err := jade.CompileDir(templatesDir, jade.DefaultDirOptions, compileOptions)
 	if err != nil {
-		logger.Printf("Error compiling templates in %s\n", templatesDir)
-		panic(err)
+		return nil, fmt.Errorf("error compiling templates in %s: %v", templatesDir, err)
 	}
 
-	templatesData.compiledTemplates = templates
-	templatesData.directory = templatesDir
-	templatesData.compileOptions = compileOptions
-
-	if Env == Dev {
-		if err != nil {
-			log.Fatalln(err)
-		}
-
-	}
+	f.templates.compiledTemplates = templates
+	f.templates.directory = templatesDir
+	f.templates.compileOptions = compileOptions
 
 	watchTemplates := f.Config.Bool("watchTemplates", true)
-	if Env == Dev && watchTemplates {
-		f.watchTemplates(templatesDir)
+	if f.Env == Dev && watchTemplates {
+		if err = f.watchTemplates(templatesDir); err != nil {
+			f.log.Error("can't watch templates", "dir", templatesDir, "error", err)
+		}
 	}
 
-	return templates
+	return templates, nil
 }
 
-func (f *Floki) watchTemplates(templatesDir string) {
-	watcher, err := fsnotify.NewWatcher()
+// watchTemplates recompiles templates when files in the directory or its subdirectories change.
+// Watching stops on Shutdown.
+func (f *Floki) watchTemplates(templatesDir string) error {
+	dirs, err := templateDirs(templatesDir)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	// Process events
-	go func() {
-		updateTimes := make(map[string]time.Time)
-
-		for {
-			select {
-			case ev := <-watcher.Event:
-
-				fileCreated := false
-
-				if ev.IsCreate() {
-					info, err := os.Stat(ev.Name)
-					if err == nil {
-						if info.IsDir() {
-							log.Println("watching new directory for changes:", ev.Name)
-
-							err = watcher.Watch(ev.Name)
-							if err != nil {
-								log.Fatal(err)
-							}
-
-						} else {
-							log.Println("watching new file for changes:", ev.Name)
-
-							err = watcher.Watch(ev.Name)
-							if err != nil {
-								log.Fatal(err)
-							}
-
-							fileCreated = true
-
-						}
-
-					}
-				}
-
-				if ev.IsDelete() {
-					if _, err := os.Stat(ev.Name); os.IsNotExist(err) {
-						log.Println("removing watch for", ev.Name)
-						watcher.RemoveWatch(ev.Name)
-					}
-				}
-
-				if ev.IsModify() || fileCreated {
-					lastUpdated, exists := updateTimes[ev.Name]
-
-					var now time.Time
-					info, err := os.Stat(ev.Name)
-					if err == nil {
-						if exists || fileCreated {
-							now = info.ModTime()
-
-							secondsGone := now.Sub(lastUpdated)
-
-							// check if file mtime was updated since last time
-							if secondsGone.Seconds() > 0.0 {
-								name := strings.Replace(ev.Name, templatesData.directory, "", 1)
-								name = strings.Replace(name, ".jade", "", 1)
+	f.templates.watchedDirs = dirs
+	f.templates.stopWatching = f.watchDirs("templates", dirs, f.recompileTemplates)
+	return nil
+}
 
-								log.Println("template updated: ", name)
+// recompileTemplates compiles all templates again. Directories created since watching
+// started are watched from now on.
+func (f *Floki) recompileTemplates() {
+	f.templates.mu.Lock()
+	defer f.templates.mu.Unlock()
 
-								/*
-									tagsI := f.GetParameter("_tags")
-									var tags template.FuncMap
-									tags = nil
-									if tagsI != nil {
-										tags = tagsI.(template.FuncMap)
-									}
-								*/
+	// @todo: build dependencies tree and recompile only needed files
+	templates, err := jade.CompileDir(f.templates.directory, jade.DefaultDirOptions, f.templates.compileOptions)
+	if err != nil {
+		f.log.Error("can't compile templates", "dir", f.templates.directory, "error", err)
+		return
+	}
 
-								// @todo: build dependencies tree and recompile only needed files
-								templates, err := jade.CompileDir(templatesData.directory, jade.DefaultDirOptions, templatesData.compileOptions)
-								for k, v := range templates {
-									/*if tags != nil {
-										v.Funcs(tags)
-									}*/
+	for name, tpl := range templates {
+		f.templates.compiledTemplates[name] = tpl
+	}
 
-									templatesData.compiledTemplates[k] = v
-								}
+	f.log.Info("templates recompiled", "dir", f.templates.directory)
 
-								//templatesData.compiledTemplates[name], err = jade.CompileFile(ev.Name, templatesData.compileOptions)
-								if err != nil {
-									log.Fatal(err)
-								}
+	if f.draining.Load() {
+		return
+	}
 
-								/* show compiled template for debugging
-								 */
-								comp := jade.New()
-								comp.ParseFile(ev.Name)
-								source, _ := comp.CompileString()
-								log.Println("compiled:", source)
+	dirs, err := templateDirs(f.templates.directory)
+	if err != nil {
+		f.log.Error("can't watch templates", "dir", f.templates.directory, "error", err)
+		return
+	}
 
-							}
-						}
+	if !reflect.DeepEqual(dirs, f.templates.watchedDirs) {
+		f.templates.stopWatching()
+		f.templates.watchedDirs = dirs
+		f.templates.stopWatching = f.watchDirs("templates", dirs, f.recompileTemplates)
+	}
+}
 
-					} else {
-						log.Println(err)
-					}
+// templateDirs returns the directory and all its subdirectories
+func templateDirs(templatesDir string) ([]string, error) {
+	var dirs []string
 
-					updateTimes[ev.Name] = now
-
-				}
-
-			case err := <-watcher.Error:
-				log.Println("error:", err)
-			}
+	err := filepath.Walk(templatesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
 		}
-	}()
 
-	err = watcher.Watch(templatesDir)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	err = filepath.Walk(templatesDir, func(path string, f os.FileInfo, err error) error {
-		if f.IsDir() {
-			log.Println("watching directory for changes:", path)
-			watcher.Watch(path)
+		if info.IsDir() {
+			dirs = append(dirs, path)
 		}
-
 		return nil
 	})
 
 	if err != nil {
-		log.Fatalf("error reading %s directory\n", templatesDir)
+		return nil, fmt.Errorf("error reading %s directory: %v", templatesDir, err)
 	}
-
-	//watcher.Close()
+	return dirs, nil
 }
 
 func (f *Floki) RegisterTag(tagName string, value interface{}) {
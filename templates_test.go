@@ -0,0 +1,49 @@
+package floki
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecompileTemplates(t *testing.T) {
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "index.jade"), []byte("index"), 0644)
+
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+	f.Env = Dev
+
+	templates, err := f.compileTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.watchStops) != 1 {
+		t.Fatalf("templates should be watched, got %d watchers", len(f.watchStops))
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "index.jade"), []byte("changed"), 0644)
+	os.Mkdir(filepath.Join(dir, "mail"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "mail", "welcome.jade"), []byte("welcome"), 0644)
+
+	f.recompileTemplates()
+
+	if templates["index"] == nil || templates["index"].Tree.Root.String() != "changed" {
+		t.Errorf("changed template should be recompiled")
+	}
+	if templates[filepath.Join("mail", "welcome")] == nil {
+		t.Errorf("template in new directory should be compiled, got %v", templates)
+	}
+
+	if len(f.templates.watchedDirs) != 2 {
+		t.Errorf("new directory should be watched, got %v", f.templates.watchedDirs)
+	}
+
+	// watchers are stopped on Shutdown
+	f.stopWatching()
+	if len(f.watchStops) != 0 {
+		t.Error("template watchers should be stopped")
+	}
+}
@@ -0,0 +1,194 @@
+package floki
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type (
+	certFiles struct {
+		cert string
+		key  string
+	}
+
+	// certStore keeps certificates loaded from files and picks one by SNI server name.
+	// Certificates can be reloaded at any time, new handshakes use new certificates
+	// while established connections are left intact.
+	certStore struct {
+		files []certFiles
+
+		mu     sync.RWMutex
+		certs  []*tls.Certificate
+		byName map[string]*tls.Certificate
+	}
+)
+
+// ListenTLS serves https on addr. Certificates are configured with:
+//     tlsCert, tlsKey   - paths to default certificate and its key
+//     tlsCertificates   - additional certificates picked by SNI:
+//                         {"example.org": {"cert": "...", "key": "..."}}
+//     tlsWatch          - reload certificates when files change, true by default
+//     tlsRedirectAddr   - address of plain http listener which redirects to https, e.g. ":80"
+// Relative paths are resolved against Root. Certificates are also reloaded on SIGHUP. Like Listen, it returns after the server is shut down.
+func (f *Floki) ListenTLS(addr string) error {
+	tlsConfig, err := f.configureTLS()
+	if err != nil {
+		return err
+	}
+
+	if redirectAddr := f.Config.Str("tlsRedirectAddr", ""); redirectAddr != "" {
+		f.AddListener("redirect", redirectAddr, redirectHandler(addr))
+	}
+
+	pidFile := f.path(f.Config.Str("pidFile", "floki.pid"))
+	return f.serve(addr, f, pidFile, tlsConfig)
+}
+
+// configureTLS loads certificates from config and creates TLS config with modern defaults
+func (f *Floki) configureTLS() (*tls.Config, error) {
+	if f.tlsConfig != nil {
+		return f.tlsConfig, nil
+	}
+
+	var files []certFiles
+
+	if cert := f.Config.Str("tlsCert", ""); cert != "" {
+		files = append(files, certFiles{f.path(cert), f.path(f.Config.Str("tlsKey", ""))})
+	}
+
+	f.Config.Map("tlsCertificates").EachMap(func(name string, conf ConfigMap) {
+		files = append(files, certFiles{f.path(conf.Str("cert", "")), f.path(conf.Str("key", ""))})
+	})
+
+	if len(files) == 0 {
+		return nil, errors.New("no TLS certificates configured, set tlsCert and tlsKey")
+	}
+
+	store := &certStore{files: files}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	f.certificates = store
+
+	f.tlsConfig = &tls.Config{
+		GetCertificate: store.getCertificate,
+		MinVersion:     tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+	}
+
+	return f.tlsConfig, nil
+}
+
+// reloadCertificates loads certificate files again. Current certificates are kept if loading fails.
+func (f *Floki) reloadCertificates() {
+	if err := f.certificates.load(); err != nil {
+		f.log.Error("can't reload TLS certificates, keeping current ones", "error", err)
+		return
+	}
+
+	f.log.Info("TLS certificates reloaded")
+}
+
+// watchCertificates reloads certificates when files in their directories change
+func (f *Floki) watchCertificates() {
+	var dirs []string
+	for _, files := range f.certificates.files {
+		dirs = append(dirs, filepath.Dir(files.cert), filepath.Dir(files.key))
+	}
+
+	f.watchDirs("TLS certificates", dirs, f.reloadCertificates)
+}
+
+// redirectHandler redirects all requests to https on tlsAddr. 308 keeps method and body of the request.
+func redirectHandler(tlsAddr string) http.Handler {
+	_, tlsPort, _ := net.SplitHostPort(tlsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := stripPort(req.Host)
+		if tlsPort != "" && tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		}
+
+		http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+func (s *certStore) load() error {
+	certs := make([]*tls.Certificate, 0, len(s.files))
+	byName := make(map[string]*tls.Certificate)
+
+	for _, files := range s.files {
+		cert, err := tls.LoadX509KeyPair(files.cert, files.key)
+		if err != nil {
+			return err
+		}
+
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return err
+		}
+		cert.Leaf = leaf
+
+		names := leaf.DNSNames
+		if len(names) == 0 && leaf.Subject.CommonName != "" {
+			names = []string{leaf.Subject.CommonName}
+		}
+
+		for _, name := range names {
+			name = strings.ToLower(name)
+			if _, exists := byName[name]; !exists {
+				byName[name] = &cert
+			}
+		}
+
+		certs = append(certs, &cert)
+	}
+
+	s.mu.Lock()
+	s.certs = certs
+	s.byName = byName
+	s.mu.Unlock()
+
+	return nil
+}
+
+// getCertificate picks certificate by exact server name, then by wildcard name.
+// The first configured certificate is used for clients which don't send SNI.
+func (s *certStore) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if name != "" {
+		if cert, ok := s.byName[name]; ok {
+			return cert, nil
+		}
+
+		if dot := strings.Index(name, "."); dot > 0 {
+			if cert, ok := s.byName["*"+name[dot:]]; ok {
+				return cert, nil
+			}
+		}
+	}
+
+	if len(s.certs) == 0 {
+		return nil, errors.New("no TLS certificates loaded")
+	}
+
+	return s.certs[0], nil
+}
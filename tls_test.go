@@ -0,0 +1,168 @@
+package floki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes self-signed certificate for names and its key, returning their paths
+func writeTestCert(t *testing.T, dir, file string, names ...string) certFiles {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := certFiles{filepath.Join(dir, file+".pem"), filepath.Join(dir, file+".key")}
+	ioutil.WriteFile(files.cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(files.key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	return files
+}
+
+func TestCertStoreGetCertificate(t *testing.T) {
+	dir := t.TempDir()
+
+	store := &certStore{files: []certFiles{
+		writeTestCert(t, dir, "default", "default.org"),
+		writeTestCert(t, dir, "example", "example.org", "www.example.org"),
+		writeTestCert(t, dir, "wildcard", "*.example.org"),
+	}}
+
+	if err := store.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"example.org":      "example.org",
+		"WWW.Example.org.": "example.org",
+		"api.example.org":  "*.example.org",
+		"default.org":      "default.org",
+		"unknown.net":      "default.org",
+		"a.b.example.org":  "default.org",
+		"":                 "default.org",
+	}
+
+	for serverName, expected := range tests {
+		cert, err := store.getCertificate(&tls.ClientHelloInfo{ServerName: serverName})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if cert.Leaf.Subject.CommonName != expected {
+			t.Errorf("%q: expected certificate of %s, got %s", serverName, expected, cert.Leaf.Subject.CommonName)
+		}
+	}
+}
+
+func TestCertStoreKeepsCertificatesOnFailedLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := writeTestCert(t, dir, "example", "example.org")
+
+	store := &certStore{files: []certFiles{files}}
+	if err := store.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// certificate is being replaced, key is not written yet
+	ioutil.WriteFile(files.key, []byte("broken"), 0600)
+
+	if err := store.load(); err == nil {
+		t.Fatal("broken key should fail loading")
+	}
+
+	cert, err := store.getCertificate(&tls.ClientHelloInfo{ServerName: "example.org"})
+	if err != nil || cert.Leaf.Subject.CommonName != "example.org" {
+		t.Errorf("current certificate should be kept, got %v", err)
+	}
+
+	empty := &certStore{}
+	if _, err = empty.getCertificate(&tls.ClientHelloInfo{}); err == nil {
+		t.Error("store without certificates should fail")
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		tlsAddr  string
+		host     string
+		expected string
+	}{
+		{":443", "example.org", "https://example.org/form?a=1"},
+		{":8443", "example.org:8080", "https://example.org:8443/form?a=1"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/form?a=1", strings.NewReader("data"))
+		req.Host = test.host
+
+		w := httptest.NewRecorder()
+		redirectHandler(test.tlsAddr).ServeHTTP(w, req)
+
+		// 308 keeps POST a POST
+		if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != test.expected {
+			t.Errorf("%s: unexpected redirect %d to %s", test.tlsAddr, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestConfigureTLSPathsRelativeToRoot(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "certs"), 0755)
+	writeTestCert(t, filepath.Join(root, "certs"), "default", "default.org")
+	writeTestCert(t, filepath.Join(root, "certs"), "example", "example.org")
+
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+	f.Root = root
+	f.Config = testConfig(t, `{
+		"tlsCert": "certs/default.pem",
+		"tlsKey": "certs/default.key",
+		"tlsCertificates": {"example.org": {"cert": "certs/example.pem", "key": "certs/example.key"}}
+	}`)
+
+	if _, err := f.configureTLS(); err != nil {
+		t.Fatalf("certificates should be found in Root, got %v", err)
+	}
+
+	cert, err := f.certificates.getCertificate(&tls.ClientHelloInfo{ServerName: "example.org"})
+	if err != nil || cert.Leaf.Subject.CommonName != "example.org" {
+		t.Errorf("certificate from tlsCertificates should be loaded, got %v", err)
+	}
+
+	// certificates are watched by serve, so -check-config doesn't start watchers
+	if len(f.watchStops) != 0 {
+		t.Errorf("configuring TLS should not start watchers, got %d", len(f.watchStops))
+	}
+}
@@ -0,0 +1,88 @@
+package floki
+
+import (
+	"github.com/howeyc/fsnotify"
+	"sync"
+	"time"
+)
+
+// watchDelay is how long watchDirs waits after the last change before calling back,
+// so files written one after another are picked up together
+var watchDelay = time.Second
+
+// watchDirs calls fn when files in dirs change. name describes watched files in log entries.
+// Watching stops when returned function is called or the application is shut down.
+func (f *Floki) watchDirs(name string, dirs []string, fn func()) (stop func()) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		f.log.Error("can't watch "+name, "error", err)
+		return func() {}
+	}
+
+	watched := make(map[string]bool)
+	for _, dir := range dirs {
+		if watched[dir] {
+			continue
+		}
+		watched[dir] = true
+
+		if err = watcher.Watch(dir); err != nil {
+			f.log.Error("can't watch "+name, "dir", dir, "error", err)
+		}
+	}
+
+	quit := make(chan struct{})
+	go f.watchLoop(name, watcher, quit, fn)
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			close(quit)
+			watcher.Close()
+		})
+	}
+
+	f.watchMu.Lock()
+	f.watchStops = append(f.watchStops, stop)
+	f.watchMu.Unlock()
+
+	return stop
+}
+
+// watchLoop calls fn once events of watcher stop coming for watchDelay, until quit is closed
+func (f *Floki) watchLoop(name string, watcher *fsnotify.Watcher, quit chan struct{}, fn func()) {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-watcher.Event:
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(watchDelay, fn)
+
+		case err := <-watcher.Error:
+			f.log.Error(name+" watcher error", "error", err)
+
+		case <-quit:
+			return
+		}
+	}
+}
+
+// stopWatching stops all watchers started by watchDirs
+func (f *Floki) stopWatching() {
+	f.watchMu.Lock()
+	stops := f.watchStops
+	f.watchStops = nil
+	f.watchMu.Unlock()
+
+	for _, stop := range stops {
+		stop()
+	}
+}
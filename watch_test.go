@@ -0,0 +1,76 @@
+package floki
+
+import (
+	"github.com/howeyc/fsnotify"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestWatchLoop(t *testing.T) {
+	defer func(delay time.Duration) { watchDelay = delay }(watchDelay)
+	watchDelay = 20 * time.Millisecond
+
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	changed := make(chan struct{}, 10)
+	quit := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		f.watchLoop("test files", watcher, quit, func() {
+			changed <- struct{}{}
+		})
+		close(stopped)
+	}()
+
+	// events coming one after another are picked up together
+	for i := 0; i < 3; i++ {
+		watcher.Event <- &fsnotify.FileEvent{Name: "cert.pem"}
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("change was not noticed")
+	}
+
+	time.Sleep(2 * watchDelay)
+	if len(changed) != 0 {
+		t.Errorf("events should be picked up together, got %d more calls", len(changed))
+	}
+
+	close(quit)
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watch loop should stop")
+	}
+}
+
+func TestStopWatching(t *testing.T) {
+	f := Must(New())
+	f.SetLog(NewLog(ioutil.Discard, LevelError, nil))
+
+	stop := f.watchDirs("test files", []string{t.TempDir()}, func() {})
+	f.watchDirs("test files", []string{t.TempDir()}, func() {})
+
+	if len(f.watchStops) != 2 {
+		t.Fatalf("expected 2 watchers, got %d", len(f.watchStops))
+	}
+
+	// stopping twice is safe
+	stop()
+	f.stopWatching()
+
+	if len(f.watchStops) != 0 {
+		t.Error("stopped watchers should be forgotten")
+	}
+}